@@ -0,0 +1,129 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// The defaults of Provider.BatchSize and Provider.BatchDelay.
+const (
+	defaultBatchSize  = 100
+	defaultBatchDelay = time.Second
+)
+
+// forEach calls fn for every index in [0, n), running up to limit calls
+// concurrently. Once a call fails no further calls are started, and the
+// error of the failed call with the lowest index is returned. If
+// continueOnError is set, all calls are made and their errors are joined in
+// the order of their indexes.
+func forEach(n, limit int, continueOnError bool, fn func(i int) error) error {
+	if limit <= 1 {
+		var errs []error
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				if !continueOnError {
+					return err
+				}
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   = make([]error, n)
+		failed bool
+		sem    = make(chan struct{}, limit)
+	)
+
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(i); err != nil {
+				mu.Lock()
+				errs[i] = err
+				failed = !continueOnError
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if continueOnError {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordContext returns the context for processing a single record of a
+// batch, limited to RecordTimeout if set.
+func (p *Provider) recordContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.RecordTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.RecordTimeout)
+}
+
+// forEachBatch calls fn for every index in [0, n) like forEach, but in
+// batches of BatchSize indexes, pausing for BatchDelay between batches.
+func (p *Provider) forEachBatch(ctx context.Context, n, limit int, fn func(i int) error) error {
+	size := p.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	delay := p.BatchDelay
+	if delay == 0 {
+		delay = defaultBatchDelay
+	}
+
+	var errs []error
+	for start := 0; start < n; start += size {
+		if start > 0 && delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(append(errs, ctx.Err())...)
+			case <-timer.C:
+			}
+		}
+
+		end := start + size
+		if end > n {
+			end = n
+		}
+		err := forEach(end-start, limit, p.ContinueOnError, func(i int) error {
+			return fn(start + i)
+		})
+		if err != nil {
+			if !p.ContinueOnError {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
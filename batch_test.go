@@ -0,0 +1,365 @@
+package bunny
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_AppendRecordsConcurrently(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	var records []libdns.Record
+	for i := 0; i < 10; i++ {
+		records = append(records, libdns.Record{Type: "TXT", Name: fmt.Sprintf("test%d", i), Value: "test", TTL: ttl})
+	}
+
+	p := &Provider{AccessKey: "key", Concurrency: 4}
+	result, err := p.AppendRecords(context.TODO(), "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != len(records) {
+		t.Fatalf("len(result) != len(records) => %d != %d", len(result), len(records))
+	}
+	for k, r := range result {
+		if r.ID == "" {
+			t.Fatalf("result[%d].ID is empty", k)
+		}
+		if r.Name != records[k].Name {
+			t.Fatalf("result[%d].Name != records[%d].Name => %s != %s", k, k, r.Name, records[k].Name)
+		}
+	}
+	if len(zone.Records) != len(records) {
+		t.Fatalf("len(zone.Records) != len(records) => %d != %d", len(zone.Records), len(records))
+	}
+}
+
+func Test_AppendRecordsOrder(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	m := &mockAPI{zones: []*mockZone{zone}, nextID: 1000}
+
+	// The earlier records take longer to create, so that they complete last
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			body, _ := io.ReadAll(r.Body)
+			record := bunnyRecord{}
+			json.Unmarshal(body, &record)
+			var i int
+			fmt.Sscanf(record.Name, "test%d", &i)
+			time.Sleep(time.Duration(8-i) * 5 * time.Millisecond)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		m.ServeHTTP(w, r)
+	}))
+
+	var records []libdns.Record
+	for i := 0; i < 8; i++ {
+		records = append(records, libdns.Record{Type: "TXT", Name: fmt.Sprintf("test%d", i), Value: "test", TTL: ttl})
+	}
+
+	p := &Provider{AccessKey: "key", Concurrency: 4}
+	result, err := p.AppendRecords(context.TODO(), "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[0].Name == "test0" {
+		t.Fatalf("the records were created in order => %+v", zone.Records)
+	}
+	if len(result) != len(records) {
+		t.Fatalf("len(result) != len(records) => %d != %d", len(result), len(records))
+	}
+	for k, r := range result {
+		if r.Name != records[k].Name {
+			t.Fatalf("result[%d].Name != records[%d].Name => %s != %s", k, k, r.Name, records[k].Name)
+		}
+	}
+
+	// SetRecords keeps the order as well
+	for k := range records {
+		records[k].Value = "new"
+	}
+	result, err = p.SetRecords(context.TODO(), "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, r := range result {
+		if r.Name != records[k].Name || r.Value != "new" {
+			t.Fatalf("result[%d] != records[%d] => %+v != %+v", k, k, r, records[k])
+		}
+	}
+}
+
+func Test_AppendRecordsWithIDs(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	records := []libdns.Record{
+		{Type: "A", Name: "www", Value: "1.2.3.4", TTL: ttl},
+		{Type: "NOPE", Name: "test", Value: "test", TTL: ttl},
+		{Type: "TXT", Name: "test", Value: "test", TTL: ttl},
+	}
+
+	p := &Provider{AccessKey: "key", ContinueOnError: true}
+	ids, err := p.AppendRecordsWithIDs(context.TODO(), "example.com", records)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(ids) != len(records) {
+		t.Fatalf("len(ids) != len(records) => %d != %d", len(ids), len(records))
+	}
+	if ids[0] != strconv.Itoa(zone.Records[0].ID) || ids[1] != "" || ids[2] != strconv.Itoa(zone.Records[1].ID) {
+		t.Fatalf("unexpected IDs => %q for %+v", ids, zone.Records)
+	}
+
+	// an existing TXT record is returned with its ID
+	ids, err = p.AppendRecordsWithIDs(context.TODO(), "example.com", records[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != strconv.Itoa(zone.Records[1].ID) || len(zone.Records) != 2 {
+		t.Fatalf("unexpected IDs => %q for %+v", ids, zone.Records)
+	}
+}
+
+func Test_ContinueOnError(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	records := []libdns.Record{
+		{Type: "TXT", Name: "test1", Value: "test1", TTL: ttl},
+		{Type: "NOPE", Name: "test2", Value: "test2", TTL: ttl},
+		{Type: "TXT", Name: "test3", Value: "test3", TTL: ttl},
+	}
+
+	// fail fast by default
+	p := &Provider{AccessKey: "key"}
+	result, err := p.AppendRecords(context.TODO(), "example.com", records)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(result) != 0 || len(zone.Records) != 1 {
+		t.Fatalf("unexpected result => %d record(s) returned, %d stored", len(result), len(zone.Records))
+	}
+
+	p.ContinueOnError = true
+	result, err = p.AppendRecords(context.TODO(), "example.com", records)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(result) != 2 || result[0].Name != "test1" || result[1].Name != "test3" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+
+	deleted, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{
+		{ID: "999999", Type: "TXT", Name: "missing"},
+		result[0],
+		result[1],
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("len(deleted) != 2 => %d", len(deleted))
+	}
+}
+
+func Test_RecordTimeout(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	m := &mockAPI{zones: []*mockZone{zone}, nextID: 1000}
+
+	// Creating the "slow" record hangs until the request is canceled
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPut && strings.Contains(string(body), `"slow"`) {
+			<-r.Context().Done()
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		m.ServeHTTP(w, r)
+	}))
+
+	records := []libdns.Record{
+		{Type: "TXT", Name: "test1", Value: "test1", TTL: ttl},
+		{Type: "TXT", Name: "slow", Value: "slow", TTL: ttl},
+		{Type: "TXT", Name: "test3", Value: "test3", TTL: ttl},
+	}
+
+	p := &Provider{AccessKey: "key", RecordTimeout: 100 * time.Millisecond, ContinueOnError: true}
+	start := time.Now()
+	result, err := p.SetRecords(context.TODO(), "example.com", records)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("SetRecords took too long => %s", elapsed)
+	}
+	if len(result) != 2 || result[0].Name != "test1" || result[1].Name != "test3" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+
+	// The context of the batch still applies to all of it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.SetRecords(ctx, "example.com", records); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func Test_BatchSize(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	var records []libdns.Record
+	for i := 0; i < 5; i++ {
+		records = append(records, libdns.Record{Type: "TXT", Name: fmt.Sprintf("test%d", i), Value: "test", TTL: ttl})
+	}
+
+	// 3 batches with 2 pauses in between
+	p := &Provider{AccessKey: "key", BatchSize: 2, BatchDelay: 50 * time.Millisecond}
+	start := time.Now()
+	result, err := p.AppendRecords(context.TODO(), "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("AppendRecords did not pause between batches => %s", elapsed)
+	}
+	if len(result) != len(records) || len(zone.Records) != len(records) {
+		t.Fatalf("unexpected result => %d record(s) returned, %d stored", len(result), len(zone.Records))
+	}
+
+	// The pause ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	p.BatchDelay = time.Hour
+	if _, err := p.SetRecords(ctx, "example.com", records); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func Test_ConcurrentUse(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	// Run with -race to detect data races
+	p := &Provider{AccessKey: "key", Concurrency: 4, IdempotentAppend: true}
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			_, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{
+				{Type: "TXT", Name: fmt.Sprintf("test%d", i), Value: "test", TTL: ttl},
+				{Type: "TXT", Name: fmt.Sprintf("test%d", i), Value: "other", TTL: ttl},
+			})
+			errs <- err
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := p.GetRecords(context.TODO(), "example.com")
+			errs <- err
+		}()
+		go func(i int) {
+			defer wg.Done()
+			_, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{
+				{Type: "TXT", Name: fmt.Sprintf("test%d", i-1), Value: "other"},
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_AppendBunnyRecords(t *testing.T) {
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.URL.Path {
+		case "/dnszone":
+			fmt.Fprint(w, `{"Items":[{"Id":1,"Domain":"example.com"}]}`)
+		default:
+			// the TTL and the timestamp are populated by the API
+			fmt.Fprint(w, `{"Id":1234,"Type":3,"Name":"test","Value":"test","Ttl":300,"MonitorType":0,"DateCreated":"2024-01-01T12:00:00"}`)
+		}
+	}))
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.AppendBunnyRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "1234" || records[0].TTL != 300*time.Second || records[0].BunnyType != bunnyTypeTXT {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if !records[0].Created.Equal(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected creation time => %s", records[0].Created)
+	}
+
+	appended, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appended) != 1 || appended[0].ID != "1234" {
+		t.Fatalf("unexpected records => %+v", appended)
+	}
+}
+
+func Test_AppendRecordsIgnoresID(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records:   []bunnyRecord{{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "old", TTL: 120}},
+	}
+	newMockAPI(t, zone)
+
+	var messages []string
+	p := &Provider{AccessKey: "key", Logger: func(msg string, records []libdns.Record) {
+		if !strings.HasPrefix(msg, "creating") {
+			return
+		}
+		for _, record := range records {
+			messages = append(messages, msg+" "+record.ID)
+		}
+	}}
+	records, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{ID: "10", Type: "TXT", Name: "test", Value: "new", TTL: ttl}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID == "10" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if len(zone.Records) != 2 || zone.Records[0].Value != "old" || zone.Records[1].Value != "new" {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("len(messages) != 1 => %v", messages)
+	}
+	for _, msg := range messages {
+		if strings.HasSuffix(msg, " 10") {
+			t.Fatalf("creation logged with the ignored ID => %s", msg)
+		}
+	}
+}
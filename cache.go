@@ -0,0 +1,44 @@
+package bunny
+
+import (
+	"strings"
+	"time"
+)
+
+// zoneCacheEntry is a zone cached by getZone, with the time it was fetched.
+type zoneCacheEntry struct {
+	zone    bunnyZone
+	fetched time.Time
+}
+
+// cachedZone returns the cached zone with the given name, unless it is
+// stale, see ZoneCacheTTL.
+func (p *Provider) cachedZone(name string) (bunnyZone, bool) {
+	if p.ZoneCacheTTL == 0 {
+		return bunnyZone{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.zoneCache[strings.ToLower(name)]
+	if !ok || (p.ZoneCacheTTL > 0 && time.Since(entry.fetched) >= p.ZoneCacheTTL) {
+		return bunnyZone{}, false
+	}
+	return entry.zone, true
+}
+
+// cacheZone caches the zone with the given name, if caching is enabled.
+func (p *Provider) cacheZone(name string, zone bunnyZone) {
+	if p.ZoneCacheTTL == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.zoneCache == nil {
+		p.zoneCache = map[string]zoneCacheEntry{}
+	}
+	p.zoneCache[strings.ToLower(name)] = zoneCacheEntry{zone: zone, fetched: time.Now()}
+}
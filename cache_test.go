@@ -0,0 +1,67 @@
+package bunny
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_ZoneCache(t *testing.T) {
+	m := newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	lookups := func() int {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		count := 0
+		for _, request := range m.requests {
+			if request == "GET /dnszone" {
+				count++
+			}
+		}
+		return count
+	}
+
+	// zones are not cached by default
+	p := &Provider{AccessKey: "key"}
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count := lookups(); count != 2 {
+		t.Fatalf("lookups() != 2 => %d", count)
+	}
+
+	p = &Provider{AccessKey: "key", ZoneCacheTTL: time.Hour}
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count := lookups(); count != 3 {
+		t.Fatalf("lookups() != 3 => %d", count)
+	}
+
+	// the zone is recreated with a new ID, and the cached entry goes stale
+	m.mu.Lock()
+	m.zones[0].ID = 2
+	m.mu.Unlock()
+
+	p.mu.Lock()
+	entry := p.zoneCache["example.com"]
+	entry.fetched = entry.fetched.Add(-time.Hour)
+	p.zoneCache["example.com"] = entry
+	p.mu.Unlock()
+
+	zone, err := p.GetZone(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.ID != 2 {
+		t.Fatalf("zone.ID != 2 => %d", zone.ID)
+	}
+	if count := lookups(); count != 4 {
+		t.Fatalf("lookups() != 4 => %d", count)
+	}
+}
@@ -2,12 +2,15 @@ package bunny
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,17 +18,119 @@ import (
 	"golang.org/x/net/publicsuffix"
 )
 
-type getAllRecordsResponse struct {
-	Records []bunnyRecord `json:"Records"`
-}
+// ErrZoneNotFound is returned when there is no Bunny.net zone for a domain.
+var ErrZoneNotFound = errors.New("zone not found")
+
+// ErrRecordNotFound is returned when a record to change is not in the zone.
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrClosed is returned by a Provider after Close was called.
+var ErrClosed = errors.New("provider is closed")
+
+// ErrAccessKeyRequired is returned, before any request is made, by a Provider
+// without an AccessKey.
+var ErrAccessKeyRequired = errors.New("access key is required")
+
+// ErrReadOnly is returned by the methods of a ReadOnly Provider that would
+// change records or zones, before any change is sent.
+var ErrReadOnly = errors.New("provider is read-only")
+
+// apiBaseURL is the base URL of the Bunny.net API.
+var apiBaseURL = "https://api.bunny.net"
 
 type getAllZonesResponse struct {
-	Zones []bunnyZone `json:"Items"`
+	Zones        []bunnyZone `json:"Items"`
+	HasMoreItems bool        `json:"HasMoreItems"`
 }
 
 type bunnyZone struct {
-	ID     int    `json:"Id"`
-	Domain string `json:"Domain"`
+	ID                       int    `json:"Id"`
+	Domain                   string `json:"Domain"`
+	CustomNameserversEnabled bool   `json:"CustomNameserversEnabled"`
+	Nameserver1              string `json:"Nameserver1"`
+	Nameserver2              string `json:"Nameserver2"`
+
+	// whether the domain is delegated to the nameservers of the zone, as
+	// last checked by Bunny.net
+	NameserversDetected  bool       `json:"NameserversDetected"`
+	NameserversNextCheck *bunnyTime `json:"NameserversNextCheck,omitempty"`
+
+	LoggingEnabled                bool `json:"LoggingEnabled"`
+	LoggingIPAnonymizationEnabled bool `json:"LoggingIPAnonymizationEnabled"`
+	LogAnonymizationType          int  `json:"LogAnonymizationType"`
+
+	DnsSecEnabled bool `json:"DnsSecEnabled"`
+
+	// RecordCount is the number of records embedded in the zone object.
+	RecordCount recordCount `json:"Records"`
+
+	// nameBase is not part of the API model. It is the part of the domain
+	// addressed by the caller in front of the zone name, if the caller
+	// addressed a domain within the zone, like "sub" for "sub.example.com"
+	// in the zone "example.com". Record names are relative to that domain
+	// for the caller, but relative to the zone for the API.
+	nameBase string
+}
+
+// domain returns the domain addressed by the caller, which record names are
+// relative to.
+func (z bunnyZone) domain() string {
+	if z.nameBase == "" {
+		return z.Domain
+	}
+	return z.nameBase + "." + z.Domain
+}
+
+// toBunnyName translates a record name relative to the domain addressed by
+// the caller, or a FQDN, to a name relative to the zone.
+func (z bunnyZone) toBunnyName(name string) string {
+	name = relativeName(name, z.domain())
+	if name == "@" {
+		name = ""
+	}
+
+	switch {
+	case z.nameBase == "":
+		return name
+	case name == "":
+		return z.nameBase
+	default:
+		return name + "." + z.nameBase
+	}
+}
+
+// fromBunnyName translates a record name relative to the zone to a name
+// relative to the domain addressed by the caller, with the apex as the empty
+// name, like toBunnyName expects it. Names outside of that domain are
+// returned as they are.
+func (z bunnyZone) fromBunnyName(name string) string {
+	if name == "@" {
+		name = ""
+	}
+	if z.nameBase == "" || !isWithin(name, z.nameBase) {
+		return name
+	}
+	return relativeName(name, z.nameBase)
+}
+
+// isWithin reports whether name is domain or a name below it, ignoring case.
+func isWithin(name, domain string) bool {
+	name, domain = strings.ToLower(unFQDN(name)), strings.ToLower(unFQDN(domain))
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+// relativeName makes name relative to domain, like libdns.RelativeName,
+// but ignoring case. The case of the remaining name is kept. Names outside
+// of domain are returned as they are, without a trailing dot.
+func relativeName(name, domain string) string {
+	name, domain = unFQDN(name), unFQDN(domain)
+	if !isWithin(name, domain) {
+		return name
+	}
+	if len(name) == len(domain) {
+		return ""
+	}
+	return name[:len(name)-len(domain)-1]
 }
 
 type bunnyRecord struct {
@@ -34,186 +139,703 @@ type bunnyRecord struct {
 	Name  string `json:"Name"`
 	Value string `json:"Value"`
 	TTL   int    `json:"Ttl"`
+
+	// type-dependent record fields
+	Priority int    `json:"Priority"`
+	Weight   int    `json:"Weight"`
+	Port     int    `json:"Port"`
+	Flags    int    `json:"Flags"`
+	Tag      string `json:"Tag"`
+
+	// CDN acceleration through a pull zone
+	Accelerated           bool   `json:"Accelerated"`
+	AcceleratedPullZoneID int    `json:"AcceleratedPullZoneId,omitempty"`
+	PullZoneID            int    `json:"PullZoneId,omitempty"`
+	LinkName              string `json:"LinkName,omitempty"`
+
+	// edge script linkage of Script records; "Enviromental" is the spelling
+	// of the API
+	ScriptID             int                `json:"ScriptId,omitempty"`
+	EnvironmentVariables []bunnyEnvVariable `json:"EnviromentalVariables,omitempty"`
+
+	// health check monitoring; the status is read-only
+	MonitorType   int `json:"MonitorType"`
+	MonitorStatus int `json:"MonitorStatus,omitempty"`
+
+	// read-only timestamps, if returned by the API
+	DateCreated  *bunnyTime `json:"DateCreated,omitempty"`
+	DateModified *bunnyTime `json:"DateModified,omitempty"`
 }
 
-func (p *Provider) doRequest(request *http.Request) ([]byte, error) {
-	request.Header.Add("accept", "application/json")
-	request.Header.Add("AccessKey", p.AccessKey)
+// bunnyEnvVariable is an environment variable passed to the edge script of
+// a Script record.
+type bunnyEnvVariable struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+func (p *Provider) doRequest(request *http.Request) ([]byte, error) {
+	response, err := p.sendRequest(request)
 	if err != nil {
 		return nil, err
 	}
 
-	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		return nil, fmt.Errorf("%s (%d)", http.StatusText(response.StatusCode), response.StatusCode)
-	}
-
 	defer response.Body.Close()
 	data, err := io.ReadAll(response.Body)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkErrorPayload(data); err != nil {
+		return nil, requestError(request, err)
+	}
+
 	return data, nil
 }
 
-func (p *Provider) getZoneID(ctx context.Context, zone string) (int, error) {
+// errorPayload is the error model of the API.
+type errorPayload struct {
+	ErrorKey string `json:"ErrorKey"`
+	Field    string `json:"Field"`
+	Message  string `json:"Message"`
+}
+
+func (e errorPayload) err() error {
+	if e.ErrorKey == "" {
+		return nil
+	}
+	if e.Field != "" {
+		return fmt.Errorf("%s (%s): %s", e.ErrorKey, e.Field, e.Message)
+	}
+	return fmt.Errorf("%s: %s", e.ErrorKey, e.Message)
+}
+
+// APIError is the error returned when the API responds with an error status.
+// It is wrapped in the error returned by the provider, so use errors.As to
+// get it.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// RetryAfter is the delay requested by the Retry-After header of the
+	// response, or 0 if it has none.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (%d)", http.StatusText(e.StatusCode), e.StatusCode)
+}
+
+// IsRateLimited reports whether the API rejected the request because too many
+// requests were sent. Retry the request after RetryAfter, if it's set.
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// requestError annotates err with the method and the URL path of the request
+// that failed. The query is left out, to keep the error short.
+func requestError(request *http.Request, err error) error {
+	return fmt.Errorf("%s %s: %w", request.Method, request.URL.Path, err)
+}
+
+// checkErrorPayload returns the error described by a successful response,
+// should the API ever embed one instead of using an error status.
+func checkErrorPayload(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil
+	}
+
+	payload := errorPayload{}
+	if err := json.Unmarshal(trimmed, &payload); err != nil {
+		return nil
+	}
+	return payload.err()
+}
+
+// protectedHeaders are set by the provider and can't be overridden with
+// Provider.Headers.
+var protectedHeaders = map[string]bool{
+	"Accesskey":       true,
+	"Accept":          true,
+	"Accept-Encoding": true,
+}
+
+// sendRequest sends the request to the API and returns the successful
+// response. The caller is responsible for closing the response body.
+func (p *Provider) sendRequest(request *http.Request) (*http.Response, error) {
+	accessKey := p.accessKey()
+	if accessKey == "" {
+		return nil, ErrAccessKeyRequired
+	}
+	if p.ReadOnly && request.Method != http.MethodGet {
+		return nil, fmt.Errorf("%w: %s %s", ErrReadOnly, request.Method, request.URL.Path)
+	}
+
+	request.Header.Add("accept", "application/json")
+	request.Header.Add("AccessKey", accessKey)
+	// Setting the header ourselves disables the transparent decompression of
+	// the transport, so the response is decompressed below.
+	request.Header.Add("accept-encoding", "gzip")
+
+	for name, value := range p.Headers {
+		if protectedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		request.Header.Set(name, value)
+	}
+
+	client, err := p.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, failed, err := p.sendOnce(client, request)
+		statusCode := 0
+		if failed != nil {
+			statusCode = failed.StatusCode
+		}
+		if err == nil || attempt >= p.MaxRetries || !p.isRetryable(request, statusCode, err) {
+			return response, err
+		}
+
+		if err := p.waitForRetry(request, attempt, failed); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// accessKey returns the AccessKey without surrounding whitespace, like a
+// trailing newline pasted along with it, which the API would reject. A
+// warning is logged the first time it is trimmed.
+func (p *Provider) accessKey() string {
+	accessKey := strings.TrimSpace(p.AccessKey)
+	if accessKey == p.AccessKey {
+		return accessKey
+	}
+
+	p.mu.Lock()
+	warn := !p.trimmedAccessKey
+	p.trimmedAccessKey = true
+	p.mu.Unlock()
+	if warn {
+		p.logEvent(LogEvent{Message: "warning: removed whitespace around the access key"})
+	}
+
+	return accessKey
+}
+
+// sendOnce sends the request to the API once and returns the successful
+// response, or else the failed response, if any, whose body is closed.
+func (p *Provider) sendOnce(client *http.Client, request *http.Request) (*http.Response, *http.Response, error) {
+	start := time.Now()
+	response, err := client.Do(request)
+	p.logRequest(request, response, time.Since(start), err)
+	if err != nil {
+		p.trace(request, nil, nil)
+		return nil, nil, err
+	}
+
+	if strings.EqualFold(response.Header.Get("content-encoding"), "gzip") {
+		reader, err := gzip.NewReader(response.Body)
+		if err != nil {
+			response.Body.Close()
+			return nil, nil, err
+		}
+		response.Body = &gzipBody{Reader: reader, body: response.Body}
+		response.Header.Del("content-encoding")
+		response.Header.Del("content-length")
+		response.ContentLength = -1
+	}
+
+	if p.Trace != nil {
+		// The body is buffered, so that it can be passed to the hook and still
+		// be read by the caller.
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		response.Body = io.NopCloser(bytes.NewReader(body))
+		p.trace(request, response, body)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		response.Body.Close()
+		apiErr := &APIError{StatusCode: response.StatusCode}
+		apiErr.RetryAfter, _ = parseRetryAfter(response.Header.Get("Retry-After"), time.Now())
+		return nil, response, requestError(request, apiErr)
+	}
+
+	return response, nil, nil
+}
+
+// httpClient returns the HTTP client shared by all requests of the provider,
+// so that connections to the API are reused.
+func (p *Provider) httpClient() (*http.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if p.HTTPClient != nil {
+		return p.HTTPClient, nil
+	}
+	if p.client == nil {
+		// A transport of our own, so that Close only affects this provider
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if p.TLSConfig != nil {
+			transport.TLSClientConfig = p.TLSConfig.Clone()
+		}
+		p.client = &http.Client{Transport: transport}
+	}
+
+	return p.client, nil
+}
+
+// trace passes the exchange to the Trace hook, if set, with the access key
+// redacted from the request.
+func (p *Provider) trace(request *http.Request, response *http.Response, body []byte) {
+	if p.Trace == nil {
+		return
+	}
+
+	redacted := request.Clone(request.Context())
+	if redacted.Header.Get("AccessKey") != "" {
+		redacted.Header.Set("AccessKey", "REDACTED")
+	}
+	if response != nil {
+		response.Request = redacted
+	}
+
+	p.Trace(redacted, response, body)
+}
+
+// gzipBody decompresses a response body, closing both the decompressor and
+// the underlying body on Close.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+func (p *Provider) getZone(ctx context.Context, zone string) (bunnyZone, error) {
 	if zone == "" {
-		return 0, fmt.Errorf("zone is an empty string")
+		return bunnyZone{}, fmt.Errorf("zone is an empty string")
+	}
+
+	if cached, ok := p.cachedZone(zone); ok {
+		return cached, nil
 	}
 
-	p.log(fmt.Sprintf("fetching zone ID for %s", zone))
+	p.log(OperationGetZone, zone, fmt.Sprintf("fetching zone ID for %s", zone))
 
 	// [page => 1] and [perPage => 5] are the smallest accepted values for the API
 	req, err := http.NewRequestWithContext(ctx, "GET",
-		fmt.Sprintf("https://api.bunny.net/dnszone?page=1&perPage=5&search=%s", url.QueryEscape(zone)), nil)
+		fmt.Sprintf("%s/dnszone?page=1&perPage=5&search=%s", apiBaseURL, url.QueryEscape(zone)), nil)
 	if err != nil {
-		return 0, err
+		return bunnyZone{}, err
 	}
 
 	data, err := p.doRequest(req)
 	if err != nil {
-		return 0, err
+		return bunnyZone{}, err
 	}
 
 	result := getAllZonesResponse{}
 	if err := json.Unmarshal(data, &result); err != nil {
-		return 0, err
+		return bunnyZone{}, err
 	}
 
 	// The API may return more than one zone with a similar name, so we will
 	// need to find an exact match.
 	for _, candidate := range result.Zones {
 		if strings.EqualFold(candidate.Domain, zone) {
-			p.log(fmt.Sprintf("done fetching zone ID %d for %s", candidate.ID, zone))
-			return candidate.ID, nil
+			p.log(OperationGetZone, zone, fmt.Sprintf("done fetching zone ID %d for %s", candidate.ID, zone))
+			p.cacheZone(zone, candidate)
+			return candidate, nil
 		}
 	}
 
-	return 0, fmt.Errorf("zone not found: %s", zone)
+	return bunnyZone{}, fmt.Errorf("%w: %s", ErrZoneNotFound, zone)
 }
 
-func (p *Provider) getAllRecords(ctx context.Context, domain string) ([]libdns.Record, error) {
-	p.log(fmt.Sprintf("fetching all records for %s", domain))
-
+// resolveZone finds the Bunny.net zone that contains domain, which may be
+// the zone itself or any name within it, like "_acme-challenge.example.com".
+// The nameBase of the returned zone is the part of domain in front of the
+// zone name, or empty if domain is the zone.
+func (p *Provider) resolveZone(ctx context.Context, domain string) (bunnyZone, error) {
 	domain = strings.ToLower(domain)
-	zone, err := publicsuffix.EffectiveTLDPlusOne(domain)
+
+	// The most specific guess wins, so that delegated sub zones are found
+	// before their parent zone.
+	for _, guess := range getBaseDomainNameGuesses(domain, p.effectiveTLDPlusOne()) {
+		zone, err := p.getZone(ctx, guess)
+		if errors.Is(err, ErrZoneNotFound) {
+			continue
+		}
+		if err != nil {
+			return bunnyZone{}, err
+		}
+
+		zone.nameBase = relativeName(domain, guess)
+		return zone, nil
+	}
+
+	zone, err := p.zoneNotFound(ctx, domain)
 	if err != nil {
-		zone = domain
+		return bunnyZone{}, err
 	}
+	zone.nameBase = relativeName(domain, zone.Domain)
+	return zone, nil
+}
 
-	suffix := "." + zone
-	var subdomain string
-	if strings.HasSuffix(domain, suffix) {
-		subdomain = strings.TrimSuffix(domain, suffix)
+// zoneNotFound returns the zone supplied by OnZoneNotFound for domain, or
+// else ErrZoneNotFound.
+func (p *Provider) zoneNotFound(ctx context.Context, domain string) (bunnyZone, error) {
+	if p.OnZoneNotFound == nil {
+		return bunnyZone{}, fmt.Errorf("%w: %s", ErrZoneNotFound, domain)
 	}
 
-	zoneID, err := p.getZoneID(ctx, zone)
+	zone, err := p.OnZoneNotFound(ctx, domain)
 	if err != nil {
-		return nil, err
+		return bunnyZone{}, err
+	}
+	if zone.ID == 0 {
+		return bunnyZone{}, fmt.Errorf("%w: %s", ErrZoneNotFound, domain)
+	}
+	if !isWithin(domain, zone.Name) {
+		return bunnyZone{}, fmt.Errorf("zone %s supplied by OnZoneNotFound does not contain %s", unFQDN(zone.Name), domain)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET",
-		fmt.Sprintf("https://api.bunny.net/dnszone/%d", zoneID), nil)
+	// The supplied zone only has its ID and name, so the zone is fetched, for
+	// the data of the zone lookup, like its delegation status.
+	p.log(OperationGetZone, domain, fmt.Sprintf("using zone ID %d supplied for %s", zone.ID, domain))
+	return p.fetchZone(ctx, zone.ID)
+}
+
+// effectiveTLDPlusOne returns the function that finds the registrable
+// domain of a name, or nil if the public suffix list is disabled.
+func (p *Provider) effectiveTLDPlusOne() func(string) (string, error) {
+	switch {
+	case p.DisablePublicSuffix:
+		return nil
+	case p.EffectiveTLDPlusOne != nil:
+		return p.EffectiveTLDPlusOne
+	default:
+		return publicsuffix.EffectiveTLDPlusOne
+	}
+}
+
+// getBaseDomainNameGuesses returns the names that could be the zone of
+// domain, from the most to the least specific: domain itself and each of
+// its parent domains, down to the registrable domain (eTLD+1) returned by
+// eTLDPlusOne. Names that are public suffixes themselves are never guessed,
+// unless eTLDPlusOne is nil, in which case every parent domain is guessed.
+//
+// If eTLDPlusOne fails, e.g. for a single label or a name that is a public
+// suffix itself, the registrable domain is unknown, so every parent domain is
+// guessed as well, down to the broadest one. Only a zone matching a guess
+// exactly is used, so the additional guesses only cost lookups.
+func getBaseDomainNameGuesses(domain string, eTLDPlusOne func(string) (string, error)) []string {
+	domain = strings.Trim(domain, ".")
+	if domain == "" {
+		return []string{}
+	}
+
+	minLabels := 1
+	if eTLDPlusOne != nil {
+		if base, err := eTLDPlusOne(domain); err == nil {
+			minLabels = strings.Count(base, ".") + 1
+		}
+	}
+
+	labels := strings.Split(domain, ".")
+	guesses := []string{}
+	for i := 0; len(labels)-i >= minLabels; i++ {
+		guesses = append(guesses, strings.Join(labels[i:], "."))
+	}
+
+	return guesses
+}
+
+func (p *Provider) getAllRecords(ctx context.Context, domain string) ([]Record, error) {
+	records := []Record{}
+	zone, err := p.eachRecord(ctx, domain, func(record Record) bool {
+		records = append(records, record)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := p.doRequest(req)
+	p.log(OperationGetRecords, domain, fmt.Sprintf("done fetching %d record(s) in zone %s", len(records), zone), libdnsRecords(records)...)
+
+	return records, nil
+}
+
+// warnLossy logs a warning if record is lossy, see Record.Lossy. Only the
+// records returned to the caller are reported, not the ones read internally,
+// e.g. to find the record an update applies to.
+func (p *Provider) warnLossy(zone string, record Record) {
+	if record.Lossy {
+		p.log(OperationGetRecords, zone, fmt.Sprintf("warning: %s record %s of %s has Bunny.net specific data that libdns.Record can't carry; it is kept when the record is updated by ID, but not when the record is re-created from its libdns form", record.Type, record.ID, record.Name), record.Record)
+	}
+}
+
+// getZoneRecords fetches all the records of the zone.
+func (p *Provider) getZoneRecords(ctx context.Context, zone bunnyZone) ([]Record, error) {
+	p.log(OperationGetRecords, zone.Domain, fmt.Sprintf("fetching all records for %s", zone.Domain))
+
+	records := []Record{}
+	err := p.eachZoneRecord(ctx, zone, func(record Record) bool {
+		records = append(records, record)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	result := getAllRecordsResponse{}
-	if err := json.Unmarshal(data, &result); err != nil {
-		return nil, err
+	p.log(OperationGetRecords, zone.Domain, fmt.Sprintf("done fetching %d record(s) in zone %s", len(records), zone.Domain), libdnsRecords(records)...)
+
+	return records, nil
+}
+
+// eachRecord streams the records of domain from the API response to fn,
+// without holding all of them in memory, until fn returns false. It returns
+// the name of the zone the records were fetched from.
+func (p *Provider) eachRecord(ctx context.Context, domain string, fn func(Record) bool) (string, error) {
+	p.log(OperationGetRecords, domain, fmt.Sprintf("fetching all records for %s", domain))
+
+	resolved, err := p.resolveZone(ctx, domain)
+	if err != nil {
+		return domain, err
+	}
+
+	return resolved.Domain, p.eachZoneRecord(ctx, resolved, fn)
+}
+
+// eachZoneRecord streams the records of zone to fn until it returns false.
+// If the zone has a nameBase, only the records at or below it are passed,
+// with their names relative to it.
+func (p *Provider) eachZoneRecord(ctx context.Context, zone bunnyZone, fn func(Record) bool) error {
+	return p.eachZoneRecordWithParams(ctx, zone, nil, fn)
+}
+
+// eachZoneRecordWithParams streams the records of zone like eachZoneRecord,
+// with the query parameters params added to the request of the zone.
+func (p *Provider) eachZoneRecordWithParams(ctx context.Context, zone bunnyZone, params url.Values, fn func(Record) bool) error {
+	endpoint := fmt.Sprintf("%s/dnszone/%d", apiBaseURL, zone.ID)
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
 	}
 
-	records := []libdns.Record{}
-	for _, resData := range result.Records {
-		if subdomain != "" {
-			resName := strings.ToLower(resData.Name)
-			// in case of a subdomain, we need to filter the records by name
-			if resName != subdomain && !strings.HasSuffix(resName, "."+subdomain) {
-				continue
+	response, err := p.sendRequest(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	seen := map[int]bool{}
+	err = decodeRecords(json.NewDecoder(response.Body), func(resData bunnyRecord) bool {
+		// in case of a subdomain, we need to filter the records by name
+		if zone.nameBase != "" && !isWithin(resData.Name, zone.nameBase) {
+			return true
+		}
+		// the same record must not be acted on twice, should the API ever
+		// list it more than once
+		if seen[resData.ID] {
+			p.log(OperationGetRecords, zone.Domain, fmt.Sprintf("warning: skipping duplicate record %d in zone %s", resData.ID, zone.Domain))
+			return true
+		}
+		seen[resData.ID] = true
+		record, err := fromBunnyRecord(resData)
+		if err != nil {
+			// a single malformed record should not fail the whole listing
+			p.logError(OperationGetRecords, zone.Domain, err, record.Record)
+		}
+		record.Name = zone.fromBunnyName(record.Name)
+		return fn(record)
+	})
+	if err != nil {
+		return requestError(req, err)
+	}
+
+	return nil
+}
+
+// decodeRecords decodes the "Records" array of a zone object one record at
+// a time, passing each to fn until it returns false.
+func decodeRecords(dec *json.Decoder, fn func(bunnyRecord) bool) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	payload := errorPayload{}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "Records":
+		case "ErrorKey", "Field", "Message":
+			var value any
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+			if str, ok := value.(string); ok {
+				switch key {
+				case "ErrorKey":
+					payload.ErrorKey = str
+				case "Field":
+					payload.Field = str
+				case "Message":
+					payload.Message = str
+				}
+			}
+			continue
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		// Records may be null for an empty zone
+		if tok == nil {
+			continue
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("unexpected token %v, expected the records array", tok)
+		}
+
+		for dec.More() {
+			record := bunnyRecord{}
+			if err := dec.Decode(&record); err != nil {
+				return err
+			}
+			if !fn(record) {
+				return nil
 			}
 		}
-		records = append(records, libdns.Record{
-			ID:    fmt.Sprint(resData.ID),
-			Type:  fromBunnyType(resData.Type),
-			Name:  resData.Name,
-			Value: resData.Value,
-			TTL:   time.Duration(resData.TTL) * time.Second,
-		})
+
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
 	}
 
-	p.log(fmt.Sprintf("done fetching %d record(s) in zone %s", len(records), zone), records...)
+	if err := payload.err(); err != nil {
+		return err
+	}
 
-	return records, nil
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("unexpected token %v, expected %v", tok, delim)
+	}
+	return nil
 }
 
-func (p *Provider) createRecord(ctx context.Context, zone string, record libdns.Record) (libdns.Record, error) {
-	p.log(fmt.Sprintf("creating %s record in zone %s", record.Type, zone), record)
+// createRecord creates a new record in the zone. Any ID of record is ignored,
+// so that a record copied from another zone, or returned by an earlier call,
+// can never update an existing record by accident.
+func (p *Provider) createRecord(ctx context.Context, zone bunnyZone, record libdns.Record) (Record, error) {
+	record.ID = ""
+	p.log(OperationCreateRecord, zone.Domain, fmt.Sprintf("creating %s record in zone %s", record.Type, zone.Domain), record)
 
-	zoneID, err := p.getZoneID(ctx, zone)
+	reqData, err := toBunnyRecord(record)
 	if err != nil {
-		return libdns.Record{}, err
+		return Record{}, err
+	}
+	reqData.TTL = p.bunnyTTL(record.TTL)
+	if err := p.checkCAATag(OperationCreateRecord, zone.Domain, reqData); err != nil {
+		return Record{}, err
+	}
+	if err := p.checkApexCNAME(OperationCreateRecord, zone, &reqData); err != nil {
+		return Record{}, err
 	}
 
-	reqData := bunnyRecord{
-		Type:  toBunnyType(record.Type),
-		Name:  record.Name,
-		Value: record.Value,
-		TTL:   int(record.TTL.Seconds()),
+	created, err := p.createBunnyRecord(ctx, zone, reqData)
+	if err != nil {
+		return Record{}, err
 	}
 
+	return created, nil
+}
+
+// createBunnyRecord creates the record in the zone as given, and returns the
+// created record with its name relative to the zone.
+func (p *Provider) createBunnyRecord(ctx context.Context, zone bunnyZone, reqData bunnyRecord) (Record, error) {
+	if err := p.checkTTL(OperationCreateRecord, zone.Domain, &reqData); err != nil {
+		return Record{}, err
+	}
+	reqData.Name = zone.toBunnyName(reqData.Name)
+
 	reqBuffer, err := json.Marshal(reqData)
 	if err != nil {
-		return libdns.Record{}, err
+		return Record{}, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "PUT",
-		fmt.Sprintf("https://api.bunny.net/dnszone/%d/records", zoneID), bytes.NewBuffer(reqBuffer))
+		fmt.Sprintf("%s/dnszone/%d/records", apiBaseURL, zone.ID), bytes.NewBuffer(reqBuffer))
 	if err != nil {
-		return libdns.Record{}, err
+		return Record{}, err
 	}
 
 	req.Header.Add("content-type", "application/json")
 	data, err := p.doRequest(req)
 	if err != nil {
-		return libdns.Record{}, err
+		return Record{}, err
 	}
 
 	result := bunnyRecord{}
 	if err := json.Unmarshal(data, &result); err != nil {
-		return libdns.Record{}, err
+		return Record{}, err
+	}
+	if result.ID == 0 {
+		return Record{}, fmt.Errorf("the API did not return the ID of the created %s record %s", fromBunnyType(reqData.Type), reqData.Name)
 	}
+	p.checkReturnedTTL(OperationCreateRecord, zone.Domain, reqData, result)
 
-	resRecord := libdns.Record{
-		ID:    fmt.Sprint(result.ID),
-		Type:  fromBunnyType(result.Type),
-		Name:  libdns.RelativeName(result.Name, zone),
-		Value: result.Value,
-		TTL:   time.Duration(result.TTL) * time.Second,
+	created, err := fromBunnyRecord(result)
+	if err != nil {
+		p.logError(OperationCreateRecord, zone.Domain, err, created.Record)
 	}
+	created.Name = zone.fromBunnyName(relativeName(created.Name, zone.Domain))
 
-	p.log(fmt.Sprintf("done creating %s record %s in zone %s", resRecord.Type, resRecord.ID, zone), resRecord)
+	p.log(OperationCreateRecord, zone.Domain, fmt.Sprintf("done creating %s record %s in zone %s", created.Type, created.ID, zone.Domain), created.Record)
 
-	return resRecord, nil
+	return created, nil
 }
 
-func (p *Provider) deleteRecord(ctx context.Context, zone string, record libdns.Record) error {
-	p.log(fmt.Sprintf("deleting %s record in zone %s", record.Type, zone), record)
-
-	zoneID, err := p.getZoneID(ctx, zone)
-	if err != nil {
-		return err
-	}
+func (p *Provider) deleteRecord(ctx context.Context, zone bunnyZone, record libdns.Record) error {
+	p.log(OperationDeleteRecord, zone.Domain, fmt.Sprintf("deleting %s record in zone %s", record.Type, zone.Domain), record)
 
 	req, err := http.NewRequestWithContext(ctx, "DELETE",
-		fmt.Sprintf("https://api.bunny.net/dnszone/%d/records/%s", zoneID, url.PathEscape(record.ID)), nil)
+		fmt.Sprintf("%s/dnszone/%d/records/%s", apiBaseURL, zone.ID, url.PathEscape(record.ID)), nil)
 	if err != nil {
 		return err
 	}
@@ -223,75 +845,172 @@ func (p *Provider) deleteRecord(ctx context.Context, zone string, record libdns.
 		return err
 	}
 
-	p.log(fmt.Sprintf("done deleting %s record %s in zone %s", record.Type, record.ID, zone), record)
+	p.log(OperationDeleteRecord, zone.Domain, fmt.Sprintf("done deleting %s record %s in zone %s", record.Type, record.ID, zone.Domain), record)
 
 	return nil
 }
 
-func (p *Provider) updateRecord(ctx context.Context, zone string, record libdns.Record) error {
-	p.log(fmt.Sprintf("updating %s record in zone %s", record.Type, zone), record)
+// updateRecord updates the record with the ID of record in the zone. stored
+// is the record as currently stored, if the caller has it, or else nil, in
+// which case it is fetched from the zone.
+func (p *Provider) updateRecord(ctx context.Context, zone bunnyZone, record libdns.Record, stored *bunnyRecord) error {
+	p.log(OperationUpdateRecord, zone.Domain, fmt.Sprintf("updating %s record in zone %s", record.Type, zone.Domain), record)
 
-	zoneID, err := p.getZoneID(ctx, zone)
+	reqData, err := toBunnyRecord(record)
 	if err != nil {
 		return err
 	}
+	reqData.TTL = p.bunnyTTL(record.TTL)
+	if err := p.checkTTL(OperationUpdateRecord, zone.Domain, &reqData); err != nil {
+		return err
+	}
+	if err := p.checkCAATag(OperationUpdateRecord, zone.Domain, reqData); err != nil {
+		return err
+	}
+	if err := p.checkApexCNAME(OperationUpdateRecord, zone, &reqData); err != nil {
+		return err
+	}
+	reqData.Name = zone.toBunnyName(reqData.Name)
 
-	reqData := bunnyRecord{
-		Type:  toBunnyType(record.Type),
-		Name:  record.Name,
-		Value: record.Value,
-		TTL:   int(record.TTL.Seconds()),
+	// Start from the stored record, so that fields libdns does not model
+	// are preserved by the update.
+	if stored == nil {
+		existing, found, err := p.getZoneRecord(ctx, zone.ID, record.ID)
+		if err != nil {
+			return err
+		}
+		if found {
+			stored = &existing
+		}
+	}
+	if stored != nil {
+		reqData = mergeBunnyRecord(*stored, reqData)
 	}
 
-	reqBuffer, err := json.Marshal(reqData)
+	data, err := p.postRecord(ctx, zone.ID, record.ID, reqData)
 	if err != nil {
 		return err
 	}
 
+	// The API answers updates without content, but if it ever returns the
+	// updated record, its TTL is checked like the TTL of created records.
+	result := bunnyRecord{}
+	if len(data) > 0 && json.Unmarshal(data, &result) == nil && result.ID != 0 {
+		p.checkReturnedTTL(OperationUpdateRecord, zone.Domain, reqData, result)
+	}
+
+	p.log(OperationUpdateRecord, zone.Domain, fmt.Sprintf("done updating %s record %s in zone %s", record.Type, record.ID, zone.Domain), record)
+
+	return nil
+}
+
+// storedRecord returns the record with the given ID of existing, as stored
+// by the API, or nil if existing has no such record.
+func storedRecord(existing []Record, id string) *bunnyRecord {
+	for _, record := range existing {
+		if record.ID == id && record.stored != nil {
+			return record.stored
+		}
+	}
+	return nil
+}
+
+// postRecord replaces the record with the given ID in the zone by reqData,
+// and returns the body of the response, if any.
+func (p *Provider) postRecord(ctx context.Context, zoneID int, id string, reqData bunnyRecord) ([]byte, error) {
+	reqBuffer, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "POST",
-		fmt.Sprintf("https://api.bunny.net/dnszone/%d/records/%s", zoneID, url.PathEscape(record.ID)), bytes.NewBuffer(reqBuffer))
+		fmt.Sprintf("%s/dnszone/%d/records/%s", apiBaseURL, zoneID, url.PathEscape(id)), bytes.NewBuffer(reqBuffer))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Add("content-type", "application/json")
 
-	_, err = p.doRequest(req)
+	return p.doRequest(req)
+}
+
+// getZoneRecord fetches the record with the given ID from the zone.
+func (p *Provider) getZoneRecord(ctx context.Context, zoneID int, id string) (bunnyRecord, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		fmt.Sprintf("%s/dnszone/%d", apiBaseURL, zoneID), nil)
 	if err != nil {
-		return err
+		return bunnyRecord{}, false, err
 	}
 
-	p.log(fmt.Sprintf("done updating %s record %s in zone %s", record.Type, record.ID, zone), record)
-
-	return nil
-}
+	response, err := p.sendRequest(req)
+	if err != nil {
+		return bunnyRecord{}, false, err
+	}
+	defer response.Body.Close()
 
-// Creates a new record if it does not exist, or updates an existing one.
-func (p *Provider) createOrUpdateRecord(ctx context.Context, zone string, record libdns.Record) (libdns.Record, error) {
-	if record.ID == "" {
-		return p.createRecord(ctx, zone, record)
+	var result bunnyRecord
+	found := false
+	err = decodeRecords(json.NewDecoder(response.Body), func(candidate bunnyRecord) bool {
+		if fmt.Sprint(candidate.ID) == id {
+			result, found = candidate, true
+		}
+		return !found
+	})
+	if err != nil {
+		return bunnyRecord{}, false, requestError(req, err)
 	}
 
-	err := p.updateRecord(ctx, zone, record)
-	return record, err
+	return result, found, nil
 }
 
-func (p *Provider) log(msg string, records ...libdns.Record) {
-	if p.Logger != nil {
-		p.Logger(msg, records)
-	} else if p.Debug {
-		fmt.Printf("[bunny] %s\n", msg)
-		for _, record := range records {
-			var id string
-			if record.ID == "" {
-				id = "(new)"
-			} else {
-				id = record.ID
+// Creates a new record if it does not exist, or updates existing ones.
+// Records without an ID are looked up in existing, the records currently
+// stored in the zone: an identical record is preferred, otherwise the
+// records with the same name and type are updated, keeping their IDs, as
+// far as MultiMatchStrategy allows. Stored records that would not change are
+// not updated. It returns the created or updated records.
+func (p *Provider) createOrUpdateRecord(ctx context.Context, zone bunnyZone, record libdns.Record, existing []Record) ([]libdns.Record, error) {
+	if record.ID != "" {
+		for _, stored := range existing {
+			if stored.ID == record.ID && p.isUnchanged(zone.domain(), stored.Record, record) {
+				p.log(OperationUpdateRecord, zone.Domain, fmt.Sprintf("skipping update of unchanged %s record %s in zone %s", stored.Type, stored.ID, zone.Domain), stored.Record)
+				return []libdns.Record{stored.Record}, nil
 			}
-			fmt.Printf("[bunny]   %s: ID=%s, TTL=%s, Priority=%d, Name=%s, Value=%s\n",
-				record.Type, id, record.TTL, record.Priority, record.Name, record.Value)
 		}
+
+		err := p.updateRecord(ctx, zone, record, storedRecord(existing, record.ID))
+		return []libdns.Record{record}, err
+	}
+
+	matches := findExistingRecords(zone.domain(), record, existing)
+	switch {
+	case len(matches) == 0:
+		created, err := p.createRecord(ctx, zone, record)
+		if err != nil {
+			return nil, err
+		}
+		return []libdns.Record{created.Record}, nil
+	case len(matches) == 1 || p.MultiMatchStrategy == MultiMatchUpdateFirst:
+		matches = matches[:1]
+	case p.MultiMatchStrategy != MultiMatchUpdateAll:
+		return nil, fmt.Errorf("unexpectedly found more than 1 %s record for %s in zone %s", record.Type, record.Name, zone.domain())
 	}
+
+	updated := make([]libdns.Record, 0, len(matches))
+	for _, match := range matches {
+		if p.isUnchanged(zone.domain(), match, record) {
+			p.log(OperationUpdateRecord, zone.Domain, fmt.Sprintf("skipping update of unchanged %s record %s in zone %s", match.Type, match.ID, zone.Domain), match)
+			updated = append(updated, match)
+			continue
+		}
+
+		record.ID = match.ID
+		if err := p.updateRecord(ctx, zone, record, storedRecord(existing, match.ID)); err != nil {
+			return updated, err
+		}
+		updated = append(updated, record)
+	}
+	return updated, nil
 }
 
 const (
@@ -341,40 +1060,61 @@ func fromBunnyType(t int) string {
 	case bunnyTypeNS:
 		return "NS"
 	default:
-		panic(fmt.Sprintf("unknown record type: %d", t))
+		// Types unknown to this package are passed through by their
+		// numeric value, so they round-trip via toBunnyType.
+		return strconv.Itoa(t)
 	}
 }
 
 // Converts the libdns record type to the Bunny.net record type.
-func toBunnyType(t string) int {
+//
+// As an escape hatch for record types added to Bunny.net before this package
+// knows about them, t may also be the raw (non-negative) numeric Bunny.net
+// type, e.g. "13".
+func toBunnyType(t string) (int, error) {
 	switch t {
 	case "A":
-		return bunnyTypeA
+		return bunnyTypeA, nil
 	case "AAAA":
-		return bunnyTypeAAAA
+		return bunnyTypeAAAA, nil
 	case "CNAME":
-		return bunnyTypeCNAME
+		return bunnyTypeCNAME, nil
 	case "TXT":
-		return bunnyTypeTXT
+		return bunnyTypeTXT, nil
 	case "MX":
-		return bunnyTypeMX
+		return bunnyTypeMX, nil
 	case "Redirect":
-		return bunnyTypeRedirect
+		return bunnyTypeRedirect, nil
 	case "Flatten":
-		return bunnyTypeFlatten
+		return bunnyTypeFlatten, nil
 	case "PullZone":
-		return bunnyTypePullZone
+		return bunnyTypePullZone, nil
 	case "SRV":
-		return bunnyTypeSRV
+		return bunnyTypeSRV, nil
 	case "CAA":
-		return bunnyTypeCAA
+		return bunnyTypeCAA, nil
 	case "PTR":
-		return bunnyTypePTR
+		return bunnyTypePTR, nil
 	case "Script":
-		return bunnyTypeScript
+		return bunnyTypeScript, nil
 	case "NS":
-		return bunnyTypeNS
+		return bunnyTypeNS, nil
 	default:
-		panic(fmt.Sprintf("unknown record type: %s", t))
+		n, err := strconv.Atoi(t)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("unknown record type: %s", t)
+		}
+		return n, nil
+	}
+}
+
+// SupportedRecordTypes returns the libdns record types supported by this
+// package, from the usual DNS record types to the Bunny.net specific ones.
+// Other types are only supported by their raw numeric Bunny.net type.
+func SupportedRecordTypes() []string {
+	types := make([]string, 0, bunnyTypeNS+1)
+	for t := bunnyTypeA; t <= bunnyTypeNS; t++ {
+		types = append(types, fromBunnyType(t))
 	}
+	return types
 }
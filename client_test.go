@@ -0,0 +1,712 @@
+package bunny
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_toBunnyType(t *testing.T) {
+	testCases := []struct {
+		recordType string
+		expected   int
+		wantErr    bool
+	}{
+		{recordType: "A", expected: bunnyTypeA},
+		{recordType: "TXT", expected: bunnyTypeTXT},
+		{recordType: "NS", expected: bunnyTypeNS},
+		// raw numeric override
+		{recordType: "3", expected: bunnyTypeTXT},
+		{recordType: "13", expected: 13},
+		{recordType: "-1", wantErr: true},
+		{recordType: "1.5", wantErr: true},
+		{recordType: "NOPE", wantErr: true},
+	}
+
+	for _, c := range testCases {
+		result, err := toBunnyType(c.recordType)
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("toBunnyType(%q) expected an error", c.recordType)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != c.expected {
+			t.Fatalf("toBunnyType(%q) != c.expected => %d != %d", c.recordType, result, c.expected)
+		}
+	}
+}
+
+func Test_fromBunnyType(t *testing.T) {
+	if result := fromBunnyType(bunnyTypeCAA); result != "CAA" {
+		t.Fatalf(`fromBunnyType(bunnyTypeCAA) != "CAA" => %s != "CAA"`, result)
+	}
+
+	// unknown types round-trip by their numeric value
+	result := fromBunnyType(13)
+	if result != "13" {
+		t.Fatalf(`fromBunnyType(13) != "13" => %s != "13"`, result)
+	}
+	if n, err := toBunnyType(result); err != nil || n != 13 {
+		t.Fatalf("toBunnyType(%q) != 13 => %d (%v)", result, n, err)
+	}
+}
+
+func Test_SupportedRecordTypes(t *testing.T) {
+	types := SupportedRecordTypes()
+	if len(types) != 13 {
+		t.Fatalf("len(types) != 13 => %d", len(types))
+	}
+
+	for _, recordType := range types {
+		n, err := toBunnyType(recordType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := strconv.Atoi(recordType); err == nil || fromBunnyType(n) != recordType {
+			t.Fatalf("%s is not a known record type", recordType)
+		}
+	}
+
+	// the list covers all types known to this package
+	if result := fromBunnyType(len(types)); result != strconv.Itoa(len(types)) {
+		t.Fatalf("fromBunnyType(%d) is not in the list => %s", len(types), result)
+	}
+}
+
+func Test_GetBunnyRecords(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+			{ID: 11, Type: bunnyTypePullZone, Name: "cdn", Value: "cdn", TTL: 120},
+			{ID: 12, Type: 13, Name: "future", Value: "future", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetBunnyRecords(context.TODO(), "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		recordType string
+		bunnyType  int
+	}{
+		{"TXT", bunnyTypeTXT},
+		{"PullZone", bunnyTypePullZone},
+		{"13", 13},
+	}
+
+	if len(records) != len(expected) {
+		t.Fatalf("len(records) != len(expected) => %d != %d", len(records), len(expected))
+	}
+
+	for k, r := range records {
+		if r.Type != expected[k].recordType {
+			t.Fatalf("r.Type != expected[%d].recordType => %s != %s", k, r.Type, expected[k].recordType)
+		}
+		if r.BunnyType != expected[k].bunnyType {
+			t.Fatalf("r.BunnyType != expected[%d].bunnyType => %d != %d", k, r.BunnyType, expected[k].bunnyType)
+		}
+	}
+}
+
+func Test_GetRecordsByBunnyType(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeRedirect, Name: "go", Value: "https://example.org", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+			{ID: 12, Type: bunnyTypeRedirect, Name: "docs", Value: "https://docs.example.org", TTL: 120},
+			{ID: 13, Type: 13, Name: "future", Value: "future", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetRecordsByBunnyType(context.TODO(), "example.com.", bunnyTypeRedirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != "10" || records[1].ID != "12" || records[0].Type != "Redirect" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+
+	records, err = p.GetRecordsByBunnyType(context.TODO(), "example.com.", 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Type != "13" || records[0].Value != "future" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+}
+
+func Test_GetRecordsByName(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token1", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "_ACME-challenge", Value: "token2", TTL: 120},
+			{ID: 13, Type: bunnyTypeTXT, Name: "_acme-challenge.www", Value: "token3", TTL: 120},
+			{ID: 14, Type: bunnyTypeA, Name: "", Value: "1.2.3.4", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	for _, name := range []string{"_acme-challenge", "_acme-challenge.example.com."} {
+		records, err := p.GetRecordsByName(context.TODO(), "example.com.", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 || records[0].ID != "10" || records[1].ID != "12" {
+			t.Fatalf("unexpected records for %s => %+v", name, records)
+		}
+	}
+
+	records, err := p.GetRecordsByName(context.TODO(), "www.example.com.", "_acme-challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "13" || records[0].Name != "_acme-challenge" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+
+	records, err = p.GetRecordsByName(context.TODO(), "example.com.", "@")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "14" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+}
+
+func Test_GetRecordsModifiedSince(t *testing.T) {
+	at := func(value string) *bunnyTime {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &bunnyTime{Time: parsed}
+	}
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "old", Value: "old", TTL: 120, DateCreated: at("2024-01-01T00:00:00Z"), DateModified: at("2024-01-02T00:00:00Z")},
+			{ID: 11, Type: bunnyTypeTXT, Name: "modified", Value: "modified", TTL: 120, DateCreated: at("2024-01-01T00:00:00Z"), DateModified: at("2024-03-01T00:00:00Z")},
+			{ID: 12, Type: bunnyTypeTXT, Name: "created", Value: "created", TTL: 120, DateCreated: at("2024-03-01T00:00:00Z")},
+			{ID: 13, Type: bunnyTypeTXT, Name: "unknown", Value: "unknown", TTL: 120},
+			{ID: 14, Type: bunnyTypeTXT, Name: "exact", Value: "exact", TTL: 120, DateModified: at("2024-02-01T00:00:00Z")},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetRecordsModifiedSince(context.TODO(), "example.com.", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].ID != "11" || records[1].ID != "12" || records[2].ID != "13" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if !records[0].Modified.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected Modified => %s", records[0].Modified)
+	}
+}
+
+func Test_GetRecordsWithParams(t *testing.T) {
+	m := &mockAPI{nextID: 1000, zones: []*mockZone{{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "www", Value: "192.0.2.1", TTL: 120},
+		},
+	}}}
+	var queries []string
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dnszone/1" {
+			queries = append(queries, r.URL.RawQuery)
+		}
+		m.ServeHTTP(w, r)
+	}))
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetRecordsWithParams(context.TODO(), "example.com.", url.Values{"type": {"3"}, "search": {"a b&c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the mock API ignores the parameters
+	if len(records) != 2 {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if len(queries) != 1 || queries[0] != "search=a+b%26c&type=3" {
+		t.Fatalf("unexpected queries => %v", queries)
+	}
+
+	// no parameters, no query
+	if _, err := p.GetRecordsWithParams(context.TODO(), "example.com.", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 || queries[1] != "" {
+		t.Fatalf("unexpected queries => %v", queries)
+	}
+}
+
+func Test_doRequestGzip(t *testing.T) {
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("accept-encoding") != "gzip" {
+			t.Errorf(`accept-encoding != "gzip" => %s`, r.Header.Get("accept-encoding"))
+		}
+
+		w.Header().Set("content-type", "application/json")
+		w.Header().Set("content-encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		defer writer.Close()
+
+		switch r.URL.Path {
+		case "/dnszone":
+			fmt.Fprint(writer, `{"Items":[{"Id":1,"Domain":"example.com"}]}`)
+		default:
+			fmt.Fprint(writer, `{"Id":1,"Domain":"example.com","Records":[{"Id":10,"Type":3,"Name":"test","Value":"test","Ttl":120}]}`)
+		}
+	}))
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].ID != "10" || records[0].Value != "test" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+}
+
+func Test_Trace(t *testing.T) {
+	newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	var paths, accessKeys []string
+	var bodies [][]byte
+	p := &Provider{
+		AccessKey: "secret",
+		Trace: func(request *http.Request, response *http.Response, body []byte) {
+			paths = append(paths, request.URL.Path)
+			accessKeys = append(accessKeys, request.Header.Get("AccessKey"))
+			bodies = append(bodies, body)
+		},
+	}
+
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("len(records) != 0 => %d", len(records))
+	}
+
+	if len(paths) != 2 || paths[0] != "/dnszone" || paths[1] != "/dnszone/1" {
+		t.Fatalf("unexpected traced paths => %v", paths)
+	}
+	for k, accessKey := range accessKeys {
+		if accessKey != "REDACTED" {
+			t.Fatalf(`accessKeys[%d] != "REDACTED" => %s`, k, accessKey)
+		}
+		if len(bodies[k]) == 0 {
+			t.Fatalf("bodies[%d] is empty", k)
+		}
+	}
+}
+
+func Test_ErrorPayload(t *testing.T) {
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch {
+		case r.URL.Path == "/dnszone":
+			fmt.Fprint(w, `{"Items":[{"Id":1,"Domain":"example.com"}]}`)
+		case r.Method == http.MethodPut:
+			// a successful status without a record
+			fmt.Fprint(w, `{}`)
+		default:
+			fmt.Fprint(w, `{"ErrorKey":"dnszone.not_found","Field":"Id","Message":"The zone could not be found"}`)
+		}
+	}))
+
+	p := &Provider{AccessKey: "key"}
+	_, err := p.GetRecords(context.TODO(), "example.com")
+	if err == nil || !strings.Contains(err.Error(), "dnszone.not_found") {
+		t.Fatalf("expected the embedded error, got %v", err)
+	}
+
+	_, err = p.AppendRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "test", Value: "test", TTL: ttl},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a created record without ID")
+	}
+
+	if err := checkErrorPayload([]byte(`{"Id":1,"Domain":"example.com"}`)); err != nil {
+		t.Fatalf("unexpected error for a zone payload: %v", err)
+	}
+}
+
+func Test_ErrorRequest(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}, fail: true}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	_, err := p.GetRecordsInZone(context.TODO(), Zone{ID: 1})
+	if err == nil || !strings.Contains(err.Error(), "GET /dnszone/1: ") {
+		t.Fatalf("expected the failed request in the error, got %v", err)
+	}
+}
+
+func Test_Headers(t *testing.T) {
+	var captured http.Header
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = r.Header.Clone()
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"Items":[{"Id":1,"Domain":"example.com"}]}`)
+	}))
+
+	p := &Provider{
+		AccessKey: "key",
+		Headers: map[string]string{
+			"X-Request-Id": "123",
+			"accesskey":    "other",
+			"Accept":       "text/plain",
+		},
+	}
+	if _, err := p.GetNameservers(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if captured.Get("X-Request-Id") != "123" {
+		t.Fatalf(`X-Request-Id != "123" => %s`, captured.Get("X-Request-Id"))
+	}
+	if captured.Get("AccessKey") != "key" || len(captured.Values("AccessKey")) != 1 {
+		t.Fatalf("AccessKey was overridden => %v", captured.Values("AccessKey"))
+	}
+	if captured.Get("Accept") != "application/json" {
+		t.Fatalf("Accept was overridden => %s", captured.Get("Accept"))
+	}
+}
+
+func Test_Close(t *testing.T) {
+	newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	p := &Provider{AccessKey: "key"}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	// Close may be called more than once
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_AccessKeyRequired(t *testing.T) {
+	m := newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	p := &Provider{}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); !errors.Is(err, ErrAccessKeyRequired) {
+		t.Fatalf("expected ErrAccessKeyRequired, got %v", err)
+	}
+	if len(m.requests) != 0 {
+		t.Fatalf("len(m.requests) != 0 => %d", len(m.requests))
+	}
+}
+
+func Test_AccessKeyWhitespace(t *testing.T) {
+	var keys []string
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("AccessKey"))
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"Items":[{"Id":1,"Domain":"example.com"}]}`)
+	}))
+
+	var warnings []string
+	p := &Provider{
+		AccessKey: " key\n",
+		EventLogger: func(e LogEvent) {
+			if strings.HasPrefix(e.Message, "warning:") {
+				warnings = append(warnings, e.Message)
+			}
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetZone(context.TODO(), "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(keys) != 2 || keys[0] != "key" || keys[1] != "key" {
+		t.Fatalf("unexpected access keys => %q", keys)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("len(warnings) != 1 => %v", warnings)
+	}
+
+	p = &Provider{AccessKey: " \t\n"}
+	if _, err := p.GetZone(context.TODO(), "example.com"); !errors.Is(err, ErrAccessKeyRequired) {
+		t.Fatalf("expected ErrAccessKeyRequired, got %v", err)
+	}
+}
+
+func Test_TLSConfig(t *testing.T) {
+	m := &mockAPI{zones: []*mockZone{{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}}, nextID: 1000}
+	server := httptest.NewTLSServer(m)
+	t.Cleanup(server.Close)
+
+	previous := apiBaseURL
+	apiBaseURL = server.URL
+	t.Cleanup(func() { apiBaseURL = previous })
+
+	// The certificate of the test server is not trusted by default
+	p := &Provider{AccessKey: "key"}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err == nil {
+		t.Fatal("expected a certificate error")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	p = &Provider{AccessKey: "key", TLSConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	p = &Provider{AccessKey: "key", HTTPClient: server.Client()}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_DuplicateRecordIDs(t *testing.T) {
+	m := newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "other", Value: "other", TTL: 120},
+		},
+	})
+
+	var warnings []string
+	p := &Provider{
+		AccessKey: "key",
+		EventLogger: func(e LogEvent) {
+			if strings.HasPrefix(e.Message, "warning:") {
+				warnings = append(warnings, e.Message)
+			}
+		},
+	}
+
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != "10" || records[1].ID != "11" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("len(warnings) != 1 => %v", warnings)
+	}
+
+	// The record is deleted only once
+	if _, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test"}}); err != nil {
+		t.Fatal(err)
+	}
+	if count := m.requestCount("DELETE"); count != 1 {
+		t.Fatalf("m.requestCount(\"DELETE\") != 1 => %d", count)
+	}
+}
+
+func Test_GetRecordByID(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "a", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test.sub", Value: "b", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	record, err := p.GetRecordByID(context.TODO(), "example.com", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "11" || record.Name != "test.sub" || record.Value != "b" {
+		t.Fatalf("unexpected record => %+v", record)
+	}
+
+	// names are relative to the given domain
+	record, err = p.GetRecordByID(context.TODO(), "sub.example.com", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Name != "test" {
+		t.Fatalf(`record.Name != "test" => %q`, record.Name)
+	}
+
+	if _, err := p.GetRecordByID(context.TODO(), "example.com", 12); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+	if _, err := p.GetRecordByID(context.TODO(), "sub.example.com", 10); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound for a record outside of the domain, got %v", err)
+	}
+}
+
+func Test_ReadOnly(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com", DnsSecEnabled: true},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+		},
+	}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key", ReadOnly: true}
+	ctx := context.TODO()
+	records := []libdns.Record{{ID: "10", Type: "TXT", Name: "test", Value: "other", TTL: ttl}}
+
+	mutations := map[string]func() error{
+		"AppendRecords": func() error { _, err := p.AppendRecords(ctx, "example.com", records); return err },
+		"AppendRecordsInZone": func() error {
+			_, err := p.AppendRecordsInZone(ctx, Zone{Zone: libdns.Zone{Name: "example.com"}, ID: 1}, records)
+			return err
+		},
+		"SetRecords": func() error { _, err := p.SetRecords(ctx, "example.com", records); return err },
+		"SetRecordsInZone": func() error {
+			_, err := p.SetRecordsInZone(ctx, Zone{Zone: libdns.Zone{Name: "example.com"}, ID: 1}, records)
+			return err
+		},
+		"DeleteRecords": func() error { _, err := p.DeleteRecords(ctx, "example.com", records); return err },
+		"DeleteRecordsInZone": func() error {
+			_, err := p.DeleteRecordsInZone(ctx, Zone{Zone: libdns.Zone{Name: "example.com"}, ID: 1}, records)
+			return err
+		},
+		"CopyZoneRecords": func() error { _, err := p.CopyZoneRecords(ctx, "example.com", "example.com", true); return err },
+		"SetDNSSEC":       func() error { _, err := p.SetDNSSEC(ctx, "example.com", false); return err },
+		"SetRecordMonitor": func() error {
+			return p.SetRecordMonitor(ctx, "example.com", "10", MonitorPing)
+		},
+		"SetZoneSettings": func() error {
+			return p.SetZoneSettings(ctx, "example.com", ZoneSettings{LogAnonymizationType: LogAnonymizationDrop})
+		},
+	}
+	for name, mutate := range mutations {
+		if err := mutate(); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+		if len(m.requests) != 0 {
+			t.Fatalf("%s: request(s) sent => %v", name, m.requests)
+		}
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodPost, http.MethodDelete} {
+		if count := m.requestCount(method); count != 0 {
+			t.Fatalf("%d %s request(s) sent", count, method)
+		}
+	}
+	if len(zone.Records) != 1 || zone.Records[0].Value != "test" || !zone.DnsSecEnabled {
+		t.Fatalf("zone changed => %+v", zone)
+	}
+
+	result, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].ID != "10" {
+		t.Fatalf("unexpected records => %+v", result)
+	}
+}
+
+func Test_GetRecordsForZones(t *testing.T) {
+	newMockAPI(t,
+		&mockZone{
+			bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+			Records: []bunnyRecord{
+				{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+				{ID: 11, Type: bunnyTypeA, Name: "www", Value: "1.2.3.4", TTL: 120},
+			},
+		},
+		&mockZone{
+			bunnyZone: bunnyZone{ID: 2, Domain: "example.org"},
+			Records: []bunnyRecord{
+				{ID: 20, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+			},
+		},
+		&mockZone{bunnyZone: bunnyZone{ID: 3, Domain: "example.net"}, fail: true},
+	)
+
+	p := &Provider{AccessKey: "key", Concurrency: 4}
+	result, err := p.GetRecordsForZones(context.TODO(), []string{"example.com", "example.org.", "example.net", "missing.com", "example.com"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !errors.Is(err, ErrZoneNotFound) || !strings.Contains(err.Error(), "example.net: ") {
+		t.Fatalf("unexpected error => %v", err)
+	}
+	if len(result) != 2 || len(result["example.com"]) != 2 || len(result["example.org."]) != 1 || result["example.org."][0].ID != "20" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	result, err = p.GetRecordsForZones(ctx, []string{"example.com", "example.org"})
+	if !errors.Is(err, context.Canceled) || len(result) != 0 {
+		t.Fatalf("unexpected result => %+v, %v", result, err)
+	}
+}
+
+func Test_UpdateUsesStoredRecords(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	var records []libdns.Record
+	for i, name := range []string{"a", "b", "c", "d"} {
+		zone.Records = append(zone.Records, bunnyRecord{ID: 10 + i, Type: bunnyTypeTXT, Name: name, Value: "old", TTL: 120, MonitorType: int(MonitorPing)})
+		records = append(records, libdns.Record{Type: "TXT", Name: name, Value: "new", TTL: ttl})
+	}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	if _, err := p.SetRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+
+	fetches := 0
+	for _, request := range m.requests {
+		if request == "GET /dnszone/1" {
+			fetches++
+		}
+	}
+	if fetches != 1 || m.requestCount("POST") != 4 {
+		t.Fatalf("unexpected requests => %q", m.requests)
+	}
+	for _, record := range zone.Records {
+		if record.Value != "new" || record.MonitorType != int(MonitorPing) {
+			t.Fatalf("unexpected zone record => %+v", record)
+		}
+	}
+}
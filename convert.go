@@ -0,0 +1,346 @@
+package bunny
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// toBunnyRecord converts a libdns record to the record model of the
+// Bunny.net API.
+func toBunnyRecord(record libdns.Record) (bunnyRecord, error) {
+	recordType, err := toBunnyType(record.Type)
+	if err != nil {
+		return bunnyRecord{}, err
+	}
+
+	result := bunnyRecord{
+		Type:  recordType,
+		Name:  record.Name,
+		Value: record.Value,
+		TTL:   toBunnyTTL(record.TTL),
+	}
+
+	switch recordType {
+	case bunnyTypeTXT:
+		// Bunny.net stores the TXT content verbatim, without the quoting of
+		// the zone file presentation format, which is also how libdns
+		// represents it. Quotes and backslashes are therefore part of the
+		// content and passed through unchanged, in both directions.
+	case bunnyTypeA, bunnyTypeAAAA:
+		// the weight of weighted records, which is 0 for plain records
+		result.Weight = int(record.Weight)
+	case bunnyTypeMX:
+		// The value may also be in the zone file form "<preference> <target>",
+		// in which case the preference is taken from it.
+		if strings.ContainsAny(strings.TrimSpace(record.Value), " \t") {
+			fromValue, ok := splitMXValue(record)
+			if !ok {
+				return bunnyRecord{}, fmt.Errorf("malformed MX value %q for %s; expected: '<target>' or '<preference> <target>'", record.Value, record.Name)
+			}
+			if record.Priority != 0 && fromValue.Priority != record.Priority {
+				return bunnyRecord{}, fmt.Errorf("MX preference in value %q for %s conflicts with priority %d", record.Value, record.Name, record.Priority)
+			}
+			record = fromValue
+		}
+		result.Priority = int(record.Priority)
+		result.Value = record.Value
+		if strings.TrimSpace(result.Value) == "" {
+			return bunnyRecord{}, fmt.Errorf("MX record %s has no target", record.Name)
+		}
+	case bunnyTypeSRV:
+		// libdns stores the SRV port and target as "<port> <target>". The
+		// value may also be in the zone file form
+		// "<priority> <weight> <port> <target>", in which case the priority
+		// and weight are taken from it.
+		if fromValue, ok := splitSRVValue(record); ok {
+			if record.Priority != 0 && fromValue.Priority != record.Priority {
+				return bunnyRecord{}, fmt.Errorf("SRV priority in value %q for %s conflicts with priority %d", record.Value, record.Name, record.Priority)
+			}
+			if record.Weight != 0 && fromValue.Weight != record.Weight {
+				return bunnyRecord{}, fmt.Errorf("SRV weight in value %q for %s conflicts with weight %d", record.Value, record.Name, record.Weight)
+			}
+			record = fromValue
+		}
+		fields := strings.Fields(record.Value)
+		if len(fields) != 2 {
+			return bunnyRecord{}, fmt.Errorf("malformed SRV value %q for %s; expected: '<port> <target>' or '<priority> <weight> <port> <target>'", record.Value, record.Name)
+		}
+		port, err := strconv.Atoi(fields[0])
+		if err != nil || port < 0 {
+			return bunnyRecord{}, fmt.Errorf("invalid SRV port %q for %s", fields[0], record.Name)
+		}
+		result.Port = port
+		result.Value = fields[1]
+		result.Priority = int(record.Priority)
+		result.Weight = int(record.Weight)
+
+		labels := strings.Split(record.Name, ".")
+		if len(labels) < 2 || len(labels[0]) < 2 || !strings.HasPrefix(labels[0], "_") {
+			return bunnyRecord{}, fmt.Errorf("SRV record %s has no service; expected a name of the form '_service._proto[.name]'", record.Name)
+		}
+		if len(labels[1]) < 2 || !strings.HasPrefix(labels[1], "_") {
+			return bunnyRecord{}, fmt.Errorf("SRV record %s has no protocol; expected a name of the form '_service._proto[.name]'", record.Name)
+		}
+	case bunnyTypeCAA:
+		// libdns stores the CAA data as `<flags> <tag> "<value>"`
+		fields := strings.SplitN(strings.TrimSpace(record.Value), " ", 3)
+		if len(fields) != 3 {
+			return bunnyRecord{}, fmt.Errorf("malformed CAA value %q for %s; expected: '<flags> <tag> \"<value>\"'", record.Value, record.Name)
+		}
+		flags, err := strconv.Atoi(fields[0])
+		if err != nil || flags < 0 || flags > 255 {
+			return bunnyRecord{}, fmt.Errorf("invalid CAA flags %q for %s", fields[0], record.Name)
+		}
+		result.Flags = flags
+		result.Tag = fields[1]
+		result.Value = strings.TrimSpace(fields[2])
+		if unquoted, err := strconv.Unquote(result.Value); err == nil {
+			result.Value = unquoted
+		}
+		if result.Tag == "" {
+			return bunnyRecord{}, fmt.Errorf("CAA record %s has no tag", record.Name)
+		}
+		if result.Value == "" {
+			return bunnyRecord{}, fmt.Errorf("CAA record %s has no value", record.Name)
+		}
+	}
+
+	return result, nil
+}
+
+// splitMXValue returns the MX record with a value of the zone file form
+// "<preference> <target>" with the preference as its priority and the target
+// as its value, and whether the value has that form.
+func splitMXValue(record libdns.Record) (libdns.Record, bool) {
+	fields := strings.Fields(record.Value)
+	if len(fields) != 2 {
+		return record, false
+	}
+	preference, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return record, false
+	}
+	record.Priority = uint(preference)
+	record.Value = fields[1]
+	return record, true
+}
+
+// splitSRVValue returns the SRV record with a value of the zone file form
+// "<priority> <weight> <port> <target>" with its priority and weight taken
+// from the value, and "<port> <target>" as its value, and whether the value
+// has that form.
+func splitSRVValue(record libdns.Record) (libdns.Record, bool) {
+	fields := strings.Fields(record.Value)
+	if len(fields) != 4 {
+		return record, false
+	}
+	var numbers [3]uint64
+	for i := range numbers {
+		number, err := strconv.ParseUint(fields[i], 10, 16)
+		if err != nil {
+			return record, false
+		}
+		numbers[i] = number
+	}
+	record.Priority = uint(numbers[0])
+	record.Weight = uint(numbers[1])
+	record.Value = fields[2] + " " + fields[3]
+	return record, true
+}
+
+// splitRRValue returns the MX or SRV record with a value of the zone file
+// form split like splitMXValue and splitSRVValue do, and whether the value
+// has that form. Records of other types never have.
+func splitRRValue(record libdns.Record) (libdns.Record, bool) {
+	switch strings.ToUpper(record.Type) {
+	case "MX":
+		return splitMXValue(record)
+	case "SRV":
+		return splitSRVValue(record)
+	}
+	return record, false
+}
+
+// mergeBunnyRecord applies the fields modeled by libdns from update onto
+// the stored record, preserving all other fields of the stored record. The
+// API updates records of all types with the same endpoint and payload, so
+// the type-specific fields of the stored record, like the script of a Script
+// record, are sent along as they are. If the update changes the type of the
+// record, the type-specific fields of the old type are dropped instead.
+func mergeBunnyRecord(stored, update bunnyRecord) bunnyRecord {
+	result := writableBunnyRecord(stored)
+	if stored.Type != update.Type {
+		result.Priority = 0
+		result.Weight = 0
+		result.Port = 0
+		result.Tag = ""
+		result.PullZoneID = 0
+		result.ScriptID = 0
+		result.EnvironmentVariables = nil
+	}
+	result.Type = update.Type
+	result.Name = update.Name
+	result.Value = update.Value
+	result.TTL = update.TTL
+
+	switch update.Type {
+	case bunnyTypeA, bunnyTypeAAAA:
+		// a record without weight keeps the weight of the stored record
+		if update.Weight != 0 {
+			result.Weight = update.Weight
+		}
+	case bunnyTypeMX:
+		result.Priority = update.Priority
+	case bunnyTypeSRV:
+		result.Priority = update.Priority
+		result.Weight = update.Weight
+		result.Port = update.Port
+	case bunnyTypeCAA:
+		result.Flags = update.Flags
+		result.Tag = update.Tag
+	}
+
+	return result
+}
+
+// writableBunnyRecord returns the stored record without its ID and read-only
+// fields, to be sent with an update.
+func writableBunnyRecord(stored bunnyRecord) bunnyRecord {
+	result := stored
+	result.ID = 0
+	result.MonitorStatus = 0
+	result.DateCreated = nil
+	result.DateModified = nil
+	return result
+}
+
+// fromBunnyRecord converts a record of the Bunny.net API to a Record. The
+// record is always converted as well as possible; a non-nil error describes
+// a problem with this specific record.
+func fromBunnyRecord(r bunnyRecord) (Record, error) {
+	result := Record{
+		Record: libdns.Record{
+			ID:    fmt.Sprint(r.ID),
+			Type:  fromBunnyType(r.Type),
+			Name:  r.Name,
+			Value: r.Value,
+			TTL:   fromBunnyTTL(r.TTL),
+		},
+		BunnyType: r.Type,
+		Flags:     r.Flags,
+
+		Accelerated: r.Accelerated,
+		PullZoneID:  r.AcceleratedPullZoneID,
+		LinkName:    r.LinkName,
+
+		ScriptID: r.ScriptID,
+
+		MonitorType:   MonitorType(r.MonitorType),
+		MonitorStatus: MonitorStatus(r.MonitorStatus),
+
+		Lossy: isLossyBunnyType(r.Type),
+
+		stored: &r,
+	}
+
+	for _, variable := range r.EnvironmentVariables {
+		result.EnvironmentVariables = append(result.EnvironmentVariables, EnvironmentVariable(variable))
+	}
+	if result.PullZoneID == 0 {
+		result.PullZoneID = r.PullZoneID
+	}
+	if r.DateCreated != nil {
+		result.Created = r.DateCreated.Time
+	}
+	if r.DateModified != nil {
+		result.Modified = r.DateModified.Time
+	}
+
+	var errs []error
+	for _, date := range []*bunnyTime{r.DateCreated, r.DateModified} {
+		if date != nil && date.invalid != "" {
+			errs = append(errs, fmt.Errorf("invalid timestamp %q of record %d", date.invalid, r.ID))
+		}
+	}
+
+	switch r.Type {
+	case bunnyTypeA, bunnyTypeAAAA:
+		result.Weight = uint(r.Weight)
+	case bunnyTypeCAA:
+		result.Value = fmt.Sprintf("%d %s %q", r.Flags, r.Tag, r.Value)
+	case bunnyTypeMX:
+		result.Priority = uint(r.Priority)
+	case bunnyTypeSRV:
+		result.Value = fmt.Sprintf("%d %s", r.Port, r.Value)
+		result.Priority = uint(r.Priority)
+		result.Weight = uint(r.Weight)
+
+		// The name is kept as-is, but callers relying on libdns.Record.ToSRV
+		// need it in the form "_service._proto[.name]".
+		labels := strings.Split(r.Name, ".")
+		if len(labels) < 2 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+			errs = append(errs, fmt.Errorf("malformed SRV record %d: name %q is not of the form '_service._proto.name'", r.ID, r.Name))
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// isLossyBunnyType reports whether records of the Bunny.net type t can't be
+// fully represented by libdns.Record.
+func isLossyBunnyType(t int) bool {
+	switch t {
+	case bunnyTypeA, bunnyTypeAAAA, bunnyTypeCNAME, bunnyTypeTXT, bunnyTypeMX,
+		bunnyTypeSRV, bunnyTypeCAA, bunnyTypePTR, bunnyTypeNS:
+		return false
+	}
+	return true
+}
+
+// bunnyTime is a timestamp of the API. The API omits the time zone of its
+// timestamps, which are in UTC.
+type bunnyTime struct {
+	time.Time
+
+	// invalid is the timestamp if it could not be parsed, in which case Time
+	// is zero. A malformed timestamp of a record must not fail the decoding
+	// of the whole zone.
+	invalid string
+}
+
+// bunnyTimeLayouts are the accepted layouts of timestamps, the last one for
+// timestamps with a time zone.
+var bunnyTimeLayouts = []string{"2006-01-02T15:04:05.999999999", time.RFC3339Nano}
+
+func (t *bunnyTime) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == nil || *value == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	for _, layout := range bunnyTimeLayouts {
+		parsed, err := time.Parse(layout, *value)
+		if err == nil {
+			t.Time = parsed.UTC()
+			return nil
+		}
+	}
+
+	t.Time = time.Time{}
+	t.invalid = *value
+	return nil
+}
+
+func (t bunnyTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(bunnyTimeLayouts[0]))
+}
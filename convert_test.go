@@ -0,0 +1,494 @@
+package bunny
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_toBunnyRecordSRV(t *testing.T) {
+	result, err := toBunnyRecord(libdns.Record{
+		Type:     "SRV",
+		Name:     "_sip._tcp",
+		Value:    "5060 sip.example.com",
+		TTL:      ttl,
+		Priority: 10,
+		Weight:   20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Value != "sip.example.com" || result.Port != 5060 || result.Priority != 10 || result.Weight != 20 {
+		t.Fatalf("unexpected SRV conversion => %+v", result)
+	}
+
+	if _, err := toBunnyRecord(libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "sip.example.com"}); err == nil {
+		t.Fatal("expected an error for a SRV value without port")
+	}
+}
+
+func Test_toBunnyRecordMX(t *testing.T) {
+	tests := []struct {
+		record   libdns.Record
+		priority int
+		value    string
+	}{
+		{libdns.Record{Type: "MX", Name: "@", Value: "mail.example.com.", Priority: 10}, 10, "mail.example.com."},
+		{libdns.Record{Type: "MX", Name: "@", Value: "10 mail.example.com."}, 10, "mail.example.com."},
+		{libdns.Record{Type: "MX", Name: "@", Value: " 20  mail.example.com. ", Priority: 20}, 20, "mail.example.com."},
+		{libdns.Record{Type: "MX", Name: "@", Value: "0 ."}, 0, "."},
+	}
+	for _, test := range tests {
+		result, err := toBunnyRecord(test.record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Priority != test.priority || result.Value != test.value {
+			t.Fatalf("unexpected MX conversion of %q => %+v", test.record.Value, result)
+		}
+	}
+
+	for _, value := range []string{"ten mail.example.com.", "10 mail.example.com. extra", "70000 mail.example.com."} {
+		if _, err := toBunnyRecord(libdns.Record{Type: "MX", Name: "@", Value: value}); err == nil {
+			t.Fatalf("expected an error for the MX value %q", value)
+		}
+	}
+	if _, err := toBunnyRecord(libdns.Record{Type: "MX", Name: "@", Value: "10 mail.example.com.", Priority: 20}); err == nil {
+		t.Fatal("expected an error for a conflicting MX preference")
+	}
+}
+
+func Test_RRFormMX(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	mx := []libdns.Record{{Type: "MX", Name: "@", Value: "10 mail.example.com.", TTL: ttl}}
+	records, err := p.AppendRecords(context.TODO(), "example.com", mx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Priority != 10 || records[0].Value != "mail.example.com." {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if zone.Records[0].Priority != 10 || zone.Records[0].Value != "mail.example.com." {
+		t.Fatalf("unexpected zone record => %+v", zone.Records[0])
+	}
+
+	// the RR form is the same record as the typed form
+	if _, err := p.SetRecords(context.TODO(), "example.com", mx); err != nil {
+		t.Fatal(err)
+	}
+	if count := m.requestCount("POST"); count != 0 {
+		t.Fatalf("m.requestCount(\"POST\") != 0 => %d", count)
+	}
+
+	if _, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{{Type: "MX", Name: "@", Value: "20 mail.example.com."}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 1 {
+		t.Fatal("deleted an MX record with another preference")
+	}
+	if _, err := p.DeleteRecords(context.TODO(), "example.com", mx); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 0 {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+}
+
+func Test_RRFormSRV(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	srv := []libdns.Record{{Type: "SRV", Name: "_sip._tcp", Value: "10 20 5060 sip.example.com.", TTL: ttl}}
+	records, err := p.AppendRecords(context.TODO(), "example.com", srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Priority != 10 || records[0].Weight != 20 || records[0].Value != "5060 sip.example.com." {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	stored := zone.Records[0]
+	if stored.Priority != 10 || stored.Weight != 20 || stored.Port != 5060 || stored.Value != "sip.example.com." {
+		t.Fatalf("unexpected zone record => %+v", stored)
+	}
+
+	// the RR form is the same record as the typed form
+	if _, err := p.SetRecords(context.TODO(), "example.com", srv); err != nil {
+		t.Fatal(err)
+	}
+	if count := m.requestCount("POST"); count != 0 {
+		t.Fatalf("m.requestCount(\"POST\") != 0 => %d", count)
+	}
+
+	if _, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{{Type: "SRV", Name: "_sip._tcp", Value: "10 30 5060 sip.example.com."}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 1 {
+		t.Fatal("deleted an SRV record with another weight")
+	}
+	if _, err := p.DeleteRecords(context.TODO(), "example.com", srv); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 0 {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+}
+
+func Test_toBunnyRecordRRFormSRV(t *testing.T) {
+	for _, record := range []libdns.Record{
+		{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 20},
+		{Type: "SRV", Name: "_sip._tcp", Value: "10 20 5060 sip.example.com"},
+		{Type: "SRV", Name: "_sip._tcp", Value: "10 20 5060 sip.example.com", Priority: 10, Weight: 20},
+	} {
+		result, err := toBunnyRecord(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Priority != 10 || result.Weight != 20 || result.Port != 5060 || result.Value != "sip.example.com" {
+			t.Fatalf("unexpected result for %q => %+v", record.Value, result)
+		}
+	}
+
+	for _, record := range []libdns.Record{
+		{Type: "SRV", Name: "_sip._tcp", Value: "10 20 5060 sip.example.com", Priority: 5},
+		{Type: "SRV", Name: "_sip._tcp", Value: "10 20 5060 sip.example.com", Weight: 5},
+		{Type: "SRV", Name: "_sip._tcp", Value: "10 20 70000 sip.example.com"},
+	} {
+		if _, err := toBunnyRecord(record); err == nil {
+			t.Fatalf("expected an error for %+v", record)
+		}
+	}
+}
+
+func Test_toBunnyRecordValidation(t *testing.T) {
+	tests := []struct {
+		record  libdns.Record
+		missing string
+	}{
+		{libdns.Record{Type: "MX", Name: "@", Value: "", Priority: 10}, "no target"},
+		{libdns.Record{Type: "MX", Name: "@", Value: "  "}, "no target"},
+		{libdns.Record{Type: "SRV", Name: "sip._tcp", Value: "5060 sip.example.com"}, "no service"},
+		{libdns.Record{Type: "SRV", Name: "_sip", Value: "5060 sip.example.com"}, "no service"},
+		{libdns.Record{Type: "SRV", Name: "_sip.tcp", Value: "5060 sip.example.com"}, "no protocol"},
+		{libdns.Record{Type: "SRV", Name: "_sip._", Value: "5060 sip.example.com"}, "no protocol"},
+		{libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5060"}, "expected: '<port> <target>'"},
+		{libdns.Record{Type: "CAA", Name: "@", Value: `0  "letsencrypt.org"`}, "no tag"},
+		{libdns.Record{Type: "CAA", Name: "@", Value: `0 issue ""`}, "no value"},
+	}
+	for _, test := range tests {
+		_, err := toBunnyRecord(test.record)
+		if err == nil || !strings.Contains(err.Error(), test.missing) {
+			t.Fatalf("expected an error with %q for %+v => %v", test.missing, test.record, err)
+		}
+		if !strings.Contains(err.Error(), test.record.Name) {
+			t.Fatalf("expected the name of the record in the error => %v", err)
+		}
+	}
+
+	// complete records are valid
+	for _, record := range []libdns.Record{
+		{Type: "MX", Name: "@", Value: "mail.example.com.", Priority: 10},
+		{Type: "SRV", Name: "_sip._tcp.example.com.", Value: "5060 sip.example.com."},
+		{Type: "CAA", Name: "@", Value: `0 issue ";"`},
+	} {
+		if _, err := toBunnyRecord(record); err != nil {
+			t.Fatalf("unexpected error for %+v => %v", record, err)
+		}
+	}
+}
+
+func Test_fromBunnyRecordSRV(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "_sip._tcp"},
+		{name: "_sip._tcp.sub"},
+		{name: "sip", wantErr: true},
+		{name: "sip.tcp", wantErr: true},
+	}
+
+	for _, c := range testCases {
+		result, err := fromBunnyRecord(bunnyRecord{ID: 1, Type: bunnyTypeSRV, Name: c.name, Value: "sip.example.com", Port: 5060, Priority: 10, Weight: 20})
+		if c.wantErr && err == nil {
+			t.Fatalf("fromBunnyRecord(%q) expected an error", c.name)
+		}
+		if !c.wantErr && err != nil {
+			t.Fatalf("fromBunnyRecord(%q) unexpected error: %v", c.name, err)
+		}
+
+		// the record is converted regardless, and the name is not trimmed
+		if result.Name != c.name {
+			t.Fatalf("result.Name != c.name => %s != %s", result.Name, c.name)
+		}
+		if result.Value != "5060 sip.example.com" || result.Priority != 10 || result.Weight != 20 {
+			t.Fatalf("unexpected SRV conversion => %+v", result)
+		}
+	}
+}
+
+func Test_GetRecordsMalformedSRV(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeSRV, Name: "sip", Value: "sip.example.com", Port: 5060, TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+		},
+	})
+
+	var errs []error
+	p := &Provider{
+		AccessKey: "key",
+		EventLogger: func(e LogEvent) {
+			if e.Err != nil {
+				errs = append(errs, e.Err)
+			}
+		},
+	}
+
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records) != 2 => %d", len(records))
+	}
+	if len(errs) != 1 {
+		t.Fatalf("len(errs) != 1 => %d", len(errs))
+	}
+}
+
+func Test_CAAConversion(t *testing.T) {
+	record := libdns.Record{Type: "CAA", Name: "", Value: `128 issue "letsencrypt.org"`, TTL: ttl}
+
+	result, err := toBunnyRecord(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Flags != 128 || result.Tag != "issue" || result.Value != "letsencrypt.org" {
+		t.Fatalf("unexpected CAA conversion => %+v", result)
+	}
+
+	back, err := fromBunnyRecord(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Value != record.Value {
+		t.Fatalf("back.Value != record.Value => %s != %s", back.Value, record.Value)
+	}
+	if back.Flags != 128 {
+		t.Fatalf("back.Flags != 128 => %d", back.Flags)
+	}
+
+	if _, err := toBunnyRecord(libdns.Record{Type: "CAA", Value: "letsencrypt.org"}); err == nil {
+		t.Fatal("expected an error for a CAA value without flags and tag")
+	}
+}
+
+func Test_UpdatePreservesFlags(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "old", TTL: 120, Flags: 1},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	_, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{ID: "10", Type: "TXT", Name: "test", Value: "new", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := zone.Records[0]
+	if stored.Value != "new" {
+		t.Fatalf(`stored.Value != "new" => %s`, stored.Value)
+	}
+	if stored.Flags != 1 {
+		t.Fatalf("stored.Flags != 1 => %d", stored.Flags)
+	}
+}
+
+func Test_TXTRoundTrip(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	values := []string{
+		`foo "bar"`,
+		`"quoted"`,
+		`v=spf1 include:_spf.example.com ~all`,
+		`back\slash \"escaped\" ; semicolon`,
+		`tab	and  spaces `,
+		`ünïcödé 🔑`,
+	}
+
+	p := &Provider{AccessKey: "key"}
+	for _, value := range values {
+		record := libdns.Record{Type: "TXT", Name: "test", Value: value, TTL: ttl}
+		created, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{record})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created[0].Value != value {
+			t.Fatalf("created[0].Value != value => %q != %q", created[0].Value, value)
+		}
+		if stored := zone.Records[len(zone.Records)-1].Value; stored != value {
+			t.Fatalf("stored value != value => %q != %q", stored, value)
+		}
+	}
+
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, record := range records {
+		if record.Value != values[k] {
+			t.Fatalf("records[%d].Value != values[%d] => %q != %q", k, k, record.Value, values[k])
+		}
+	}
+}
+
+func Test_RecordTimestamps(t *testing.T) {
+	var records []bunnyRecord
+	err := json.Unmarshal([]byte(`[
+		{"Id": 1, "Type": 3, "Name": "a", "Value": "a", "DateCreated": "2024-01-02T03:04:05", "DateModified": "2024-02-03T04:05:06.789Z"},
+		{"Id": 2, "Type": 3, "Name": "b", "Value": "b", "DateCreated": null},
+		{"Id": 3, "Type": 3, "Name": "c", "Value": "c"}
+	]`), &records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := fromBunnyRecord(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	modified := time.Date(2024, 2, 3, 4, 5, 6, 789000000, time.UTC)
+	if !record.Created.Equal(created) {
+		t.Fatalf("record.Created != created => %s != %s", record.Created, created)
+	}
+	if !record.Modified.Equal(modified) {
+		t.Fatalf("record.Modified != modified => %s != %s", record.Modified, modified)
+	}
+
+	for _, r := range records[1:] {
+		record, err := fromBunnyRecord(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !record.Created.IsZero() || !record.Modified.IsZero() {
+			t.Fatalf("expected zero timestamps => %+v", record)
+		}
+	}
+
+	// An invalid timestamp is left zero and reported for its record only
+	invalid := bunnyRecord{}
+	if err := json.Unmarshal([]byte(`{"Id": 4, "Type": 3, "DateCreated": "yesterday"}`), &invalid); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := fromBunnyRecord(invalid); err == nil || !record.Created.IsZero() {
+		t.Fatalf("expected an error and a zero timestamp => %v, %s", err, record.Created)
+	}
+
+	// The timestamps are read-only, so they are not sent with an update
+	update := mergeBunnyRecord(records[0], bunnyRecord{Type: bunnyTypeTXT, Name: "a", Value: "b"})
+	data, err := json.Marshal(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Date") {
+		t.Fatalf("update contains timestamps => %s", data)
+	}
+}
+
+func Test_LossyRecords(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeRedirect, Name: "old", Value: "https://example.com", TTL: 120},
+			{ID: 11, Type: bunnyTypeScript, Name: "edge", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+		},
+	})
+
+	var warnings []LogEvent
+	p := &Provider{
+		AccessKey: "key",
+		EventLogger: func(e LogEvent) {
+			if strings.HasPrefix(e.Message, "warning:") {
+				warnings = append(warnings, e)
+			}
+		},
+	}
+
+	records, err := p.GetBunnyRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !records[0].Lossy || !records[1].Lossy || records[2].Lossy {
+		t.Fatalf("unexpected Lossy => %t, %t, %t", records[0].Lossy, records[1].Lossy, records[2].Lossy)
+	}
+	if len(warnings) != 2 || warnings[0].Records[0].ID != "10" || warnings[1].Records[0].ID != "11" {
+		t.Fatalf("unexpected warnings => %+v", warnings)
+	}
+
+	// the records read internally by writes are not reported
+	warnings = nil
+	_, err = p.AppendRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "test", Value: "other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("len(warnings) != 0 => %+v", warnings)
+	}
+}
+
+func Test_InvalidRecordTimestamp(t *testing.T) {
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		zone := `{"Id":1,"Domain":"example.com","Records":[{"Id":10,"Type":3,"Name":"test","Value":"test","Ttl":120,"DateModified":"14/10/2026"}]}`
+		switch r.URL.Path {
+		case "/dnszone":
+			fmt.Fprintf(w, `{"Items":[%s]}`, zone)
+		case "/dnszone/1":
+			fmt.Fprint(w, zone)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	var errs []error
+	p := &Provider{
+		AccessKey: "key",
+		EventLogger: func(e LogEvent) {
+			if e.Err != nil {
+				errs = append(errs, e.Err)
+			}
+		},
+	}
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "10" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "14/10/2026") {
+		t.Fatalf("unexpected errors => %v", errs)
+	}
+}
@@ -0,0 +1,114 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/libdns/libdns"
+)
+
+// CopyZoneRecords creates the records of srcDomain in dstDomain, e.g. to
+// migrate a zone or to promote a staging zone. It returns the records that
+// were created or updated in dstDomain.
+//
+// srcDomain may also be a name within a zone, like "staging.example.com", in
+// which case only the records at or below it are copied, with their names
+// made relative to it. The NS records at the apex are managed by Bunny.net
+// and are never copied. Only the data modeled by libdns is copied.
+//
+// Records that already exist identically in dstDomain are skipped. The
+// other records are created next to the existing records of dstDomain,
+// unless overwrite is set, in which case the existing records with the same
+// name and type are updated instead, like with SetRecords: each record of an
+// RRset updates a distinct record of the RRset in dstDomain, and the records
+// beyond them are created. Other records of dstDomain are kept.
+func (p *Provider) CopyZoneRecords(ctx context.Context, srcDomain, dstDomain string, overwrite bool) ([]libdns.Record, error) {
+	src, dst := unFQDN(srcDomain), unFQDN(dstDomain)
+
+	dstZone, err := p.resolveZoneForWrite(ctx, dst)
+	if err != nil {
+		p.logError(OperationGetZone, dst, err)
+		return nil, err
+	}
+
+	var records []libdns.Record
+	_, err = p.eachRecord(ctx, src, func(record Record) bool {
+		records = append(records, record.Record)
+		return true
+	})
+	if err != nil {
+		p.logError(OperationGetRecords, src, err)
+		return nil, err
+	}
+
+	existingRecords, err := p.getZoneRecords(ctx, dstZone)
+	if err != nil {
+		p.logError(OperationGetRecords, dst, err)
+		return nil, err
+	}
+
+	var toCopy []libdns.Record
+	for _, record := range records {
+		record.ID = ""
+
+		if isApexNS(dst, record) {
+			continue
+		}
+		toCopy = append(toCopy, record)
+	}
+
+	// With overwrite, the records of an RRset are paired with distinct
+	// records of the RRset in dstDomain, like with SetRecords, so that each
+	// of them is either updated or created, rather than all overwriting the
+	// same record.
+	var pairs []int
+	if overwrite {
+		pairs = pairRecords(dst, toCopy, existingRecords)
+	}
+
+	copiedRecords := make([][]libdns.Record, len(toCopy))
+
+	err = forEach(len(toCopy), 1, p.ContinueOnError, func(i int) error {
+		record := toCopy[i]
+
+		pair := -1
+		exists := false
+		if overwrite {
+			pair = pairs[i]
+			exists = pair >= 0 && sameRecord(dst, existingRecords[pair].Record, record)
+		} else {
+			for _, existing := range existingRecords {
+				if sameRecord(dst, existing.Record, record) {
+					exists = true
+					break
+				}
+			}
+		}
+		if exists {
+			p.log(OperationCreateRecord, dst, fmt.Sprintf("skipping copy of %s record %s, as it already exists in zone %s", record.Type, record.Name, dst), record)
+			return nil
+		}
+
+		if pair >= 0 {
+			record.ID = existingRecords[pair].ID
+			if err := p.updateRecord(ctx, dstZone, record, storedRecord(existingRecords, record.ID)); err != nil {
+				p.logError(OperationUpdateRecord, dst, err, record)
+				return err
+			}
+		} else {
+			created, err := p.createRecord(ctx, dstZone, record)
+			if err != nil {
+				p.logError(OperationCreateRecord, dst, err, record)
+				return err
+			}
+			record = created.Record
+		}
+		copiedRecords[i] = []libdns.Record{record}
+		return nil
+	})
+	if err != nil && !p.ContinueOnError {
+		return nil, err
+	}
+
+	return p.outputRecords(dst, flatten(copiedRecords)), err
+}
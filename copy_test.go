@@ -0,0 +1,104 @@
+package bunny
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_CopyZoneRecords(t *testing.T) {
+	staging := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeNS, Name: "", Value: "kiki.bunny.net", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "staging", Value: "127.0.0.1", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "www.staging", Value: "new", TTL: 120},
+			{ID: 13, Type: bunnyTypeA, Name: "www", Value: "127.0.0.2", TTL: 120},
+		},
+	}
+	prod := &mockZone{
+		bunnyZone: bunnyZone{ID: 2, Domain: "example.org"},
+		Records: []bunnyRecord{
+			{ID: 20, Type: bunnyTypeNS, Name: "", Value: "coco.bunny.net", TTL: 120},
+			{ID: 21, Type: bunnyTypeA, Name: "", Value: "127.0.0.1", TTL: 120},
+			{ID: 22, Type: bunnyTypeTXT, Name: "www", Value: "old", TTL: 120},
+		},
+	}
+	newMockAPI(t, staging, prod)
+
+	p := &Provider{AccessKey: "key"}
+
+	// "staging.example.com" is not a zone, so its records are copied with
+	// their names relative to it; the A record already exists.
+	copied, err := p.CopyZoneRecords(context.TODO(), "staging.example.com.", "example.org.", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied) != 1 || copied[0].Name != "www" || copied[0].Value != "new" {
+		t.Fatalf("unexpected copied records => %+v", copied)
+	}
+	if len(prod.Records) != 4 {
+		t.Fatalf("len(prod.Records) != 4 => %d", len(prod.Records))
+	}
+
+	prod.Records = prod.Records[:3]
+	copied, err = p.CopyZoneRecords(context.TODO(), "staging.example.com", "example.org", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied) != 1 || copied[0].ID != "22" {
+		t.Fatalf("unexpected copied records => %+v", copied)
+	}
+	if len(prod.Records) != 3 || prod.Records[2].Value != "new" {
+		t.Fatalf("unexpected records in example.org => %+v", prod.Records)
+	}
+
+	// The apex NS records are never copied
+	copied, err = p.CopyZoneRecords(context.TODO(), "example.com", "example.org", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range copied {
+		if record.Type == "NS" {
+			t.Fatalf("apex NS record was copied => %+v", record)
+		}
+	}
+	if len(copied) != 3 {
+		t.Fatalf("len(copied) != 3 => %d", len(copied))
+	}
+}
+
+func Test_CopyZoneRecordsRRset(t *testing.T) {
+	src := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "1.1.1.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "www", Value: "2.2.2.2", TTL: 120},
+			{ID: 12, Type: bunnyTypeA, Name: "www", Value: "3.3.3.3", TTL: 120},
+		},
+	}
+	dst := &mockZone{
+		bunnyZone: bunnyZone{ID: 2, Domain: "example.org"},
+		Records: []bunnyRecord{
+			{ID: 20, Type: bunnyTypeA, Name: "www", Value: "9.9.9.9", TTL: 120},
+		},
+	}
+	newMockAPI(t, src, dst)
+
+	p := &Provider{AccessKey: "key"}
+	copied, err := p.CopyZoneRecords(context.TODO(), "example.com", "example.org", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first record updates the stored record, the others are created
+	if len(copied) != 3 || copied[0].ID != "20" || copied[1].ID == "20" || copied[2].ID == "20" || copied[1].ID == copied[2].ID {
+		t.Fatalf("unexpected copied records => %+v", copied)
+	}
+	values := map[string]bool{}
+	for _, record := range dst.Records {
+		values[record.Value] = true
+	}
+	if len(dst.Records) != 3 || !values["1.1.1.1"] || !values["2.2.2.2"] || !values["3.3.3.3"] {
+		t.Fatalf("unexpected records in example.org => %+v", dst.Records)
+	}
+}
@@ -0,0 +1,143 @@
+package bunny
+
+import (
+	"context"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// DiffRecords computes the changes that make the zone contain exactly the
+// desired records, without changing the zone:
+//
+//   - toCreate are the desired records with no counterpart in the zone.
+//   - toUpdate are the desired records that replace a stored record, with
+//     the ID of that record. A desired record replaces the stored record
+//     identical to it (see RecordKey) if only its TTL differs, or else a
+//     stored record with the same name and type that is not desired.
+//   - toDelete are the stored records that are not desired.
+//
+// A desired record without TTL matches any TTL, unless DefaultTTL is set.
+// The NS records at the apex of the zone are managed by Bunny.net and never
+// deleted.
+func (p *Provider) DiffRecords(ctx context.Context, zone string, desired []libdns.Record) (toCreate, toUpdate, toDelete []libdns.Record, err error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return nil, nil, nil, err
+	}
+
+	existing, err := p.getZoneRecords(ctx, resolved)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return nil, nil, nil, err
+	}
+
+	toCreate, toUpdate, toDelete = p.diffRecords(resolved, desired, existing)
+	return toCreate, toUpdate, p.outputRecords(unFQDN(zone), toDelete), nil
+}
+
+// diffRecords computes the changes that make the zone contain exactly the
+// desired records, given its existing records, like DiffRecords.
+func (p *Provider) diffRecords(zone bunnyZone, desired []libdns.Record, existing []Record) (toCreate, toUpdate, toDelete []libdns.Record) {
+	domain := zone.domain()
+	pairs := pairRecords(domain, desired, existing)
+	matched := make([]bool, len(existing))
+	for _, pair := range pairs {
+		if pair >= 0 {
+			matched[pair] = true
+		}
+	}
+
+	// The records paired with an identical record only need an update if
+	// their TTL differs; they are listed before the records whose data
+	// changes.
+	var changed []libdns.Record
+	for i, record := range desired {
+		switch {
+		case pairs[i] < 0:
+			toCreate = append(toCreate, record)
+		case sameRecord(domain, existing[pairs[i]].Record, record):
+			if !p.ttlMatches(record.TTL, existing[pairs[i]].TTL) {
+				record.ID = existing[pairs[i]].ID
+				toUpdate = append(toUpdate, record)
+			}
+		default:
+			record.ID = existing[pairs[i]].ID
+			changed = append(changed, record)
+		}
+	}
+	toUpdate = append(toUpdate, changed...)
+
+	for i, record := range existing {
+		if matched[i] || (zone.nameBase == "" && isApexNS(zone.Domain, record.Record)) {
+			continue
+		}
+		toDelete = append(toDelete, record.Record)
+	}
+
+	return toCreate, toUpdate, toDelete
+}
+
+// pairRecords pairs each desired record with a distinct stored record of
+// existing, with the rules of findExistingRecords: the record identical to
+// it (see RecordKey), or else a record of the same RRset with its value, or
+// else a record of the same RRset with its weight, or else any record of the
+// same RRset that is not paired yet. It returns, for each desired record,
+// the index of its stored record, or -1 if there is none. Each rule is
+// applied to all desired records before the next one, so that a value or
+// weight change of one record of an RRset does not shift the others.
+func pairRecords(zone string, desired []libdns.Record, existing []Record) []int {
+	pairs := make([]int, len(desired))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	matched := make([]bool, len(existing))
+
+	rules := []func(candidate, record libdns.Record) bool{
+		func(candidate, record libdns.Record) bool {
+			return sameRecord(zone, candidate, record)
+		},
+		func(candidate, record libdns.Record) bool {
+			return sameNameAndType(zone, candidate, record) && sameValue(record.Type, candidate.Value, record.Value)
+		},
+		func(candidate, record libdns.Record) bool {
+			return sameNameAndType(zone, candidate, record) && record.Weight != 0 && candidate.Weight == record.Weight
+		},
+		func(candidate, record libdns.Record) bool {
+			return sameNameAndType(zone, candidate, record)
+		},
+	}
+	for _, rule := range rules {
+		for i, record := range desired {
+			if pairs[i] >= 0 {
+				continue
+			}
+			pairs[i] = findUnmatched(existing, matched, func(candidate libdns.Record) bool {
+				return rule(candidate, record)
+			})
+			if pairs[i] >= 0 {
+				matched[pairs[i]] = true
+			}
+		}
+	}
+
+	return pairs
+}
+
+// sameNameAndType reports whether a and b have the same name and type, i.e.
+// belong to the same RRset.
+func sameNameAndType(zone string, a, b libdns.Record) bool {
+	return strings.EqualFold(a.Type, b.Type) && normalizeName(a.Name, zone) == normalizeName(b.Name, zone)
+}
+
+// findUnmatched returns the index of the first record of existing that is
+// not matched yet and for which fn returns true, or -1 if there is none.
+func findUnmatched(existing []Record, matched []bool, fn func(libdns.Record) bool) int {
+	for i, candidate := range existing {
+		if !matched[i] && fn(candidate.Record) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package bunny
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_DiffRecords(t *testing.T) {
+	m := newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 9, Type: bunnyTypeNS, Name: "", Value: "kiki.bunny.net", TTL: 120},
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "www", Value: "127.0.0.2", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "test", Value: "old", TTL: 120},
+			{ID: 13, Type: bunnyTypeTXT, Name: "ttl", Value: "ttl", TTL: 120},
+			{ID: 14, Type: bunnyTypeTXT, Name: "gone", Value: "gone", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	toCreate, toUpdate, toDelete, err := p.DiffRecords(context.TODO(), "example.com", []libdns.Record{
+		// unchanged
+		{Type: "A", Name: "WWW", Value: "127.0.0.2", TTL: ttl},
+		{Type: "A", Name: "www", Value: "127.0.0.1"},
+		// value change
+		{Type: "TXT", Name: "test", Value: "new", TTL: ttl},
+		// TTL change
+		{Type: "TXT", Name: "ttl", Value: "ttl", TTL: time.Hour},
+		// new record
+		{Type: "TXT", Name: "new", Value: "new", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toCreate) != 1 || toCreate[0].Name != "new" {
+		t.Fatalf("unexpected toCreate => %+v", toCreate)
+	}
+	if len(toUpdate) != 2 || toUpdate[0].ID != "13" || toUpdate[0].TTL != time.Hour || toUpdate[1].ID != "12" || toUpdate[1].Value != "new" {
+		t.Fatalf("unexpected toUpdate => %+v", toUpdate)
+	}
+	if len(toDelete) != 1 || toDelete[0].ID != "14" {
+		t.Fatalf("unexpected toDelete => %+v", toDelete)
+	}
+
+	// The zone is left unchanged
+	for _, method := range []string{"PUT", "POST", "DELETE"} {
+		if count := m.requestCount(method); count != 0 {
+			t.Fatalf("m.requestCount(%q) != 0 => %d", method, count)
+		}
+	}
+}
+
+func Test_DiffRecordsDefaultTTL(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key", DefaultTTL: time.Hour}
+	_, toUpdate, _, err := p.DiffRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(toUpdate) != 1 || toUpdate[0].ID != "10" {
+		t.Fatalf("unexpected toUpdate => %+v", toUpdate)
+	}
+}
@@ -0,0 +1,127 @@
+package bunny
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// DSRecord is the DS record of a zone signed with DNSSEC, which has to be
+// added at the registrar of the domain to establish the chain of trust.
+type DSRecord struct {
+	KeyTag     int
+	Algorithm  int
+	DigestType int
+	Digest     string
+
+	// Flags and PublicKey are those of the DNSKEY the DS record refers to.
+	Flags     int
+	PublicKey string
+
+	// Record is the DS record in zone file presentation format, if returned
+	// by the API.
+	Record string
+}
+
+// dnssecResponse is the DNSSEC model of the API.
+type dnssecResponse struct {
+	Enabled    bool   `json:"Enabled"`
+	DsRecord   string `json:"DsRecord"`
+	Digest     string `json:"Digest"`
+	DigestType int    `json:"DigestType"`
+	Algorithm  int    `json:"Algorithm"`
+	PublicKey  string `json:"PublicKey"`
+	KeyTag     int    `json:"KeyTag"`
+	Flags      int    `json:"Flags"`
+}
+
+// GetDNSSEC reports whether DNSSEC is enabled for the zone, or for the zone
+// of a name within it.
+func (p *Provider) GetDNSSEC(ctx context.Context, zone string) (bool, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return false, err
+	}
+
+	result, err := p.fetchZone(ctx, resolved.ID)
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return false, err
+	}
+
+	return result.DnsSecEnabled, nil
+}
+
+// SetDNSSEC enables or disables DNSSEC for the zone. When enabling it, the
+// DS record of the zone is returned, if the API provides it, to be added at
+// the registrar; otherwise the returned DS record is nil. The API has no
+// endpoint to read the DS record later, so it should be kept.
+func (p *Provider) SetDNSSEC(ctx context.Context, zone string, enabled bool) (*DSRecord, error) {
+	if p.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	p.log(OperationUpdateZone, unFQDN(zone), fmt.Sprintf("setting DNSSEC of zone %s to %t", unFQDN(zone), enabled))
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationUpdateZone, unFQDN(zone), err)
+		return nil, err
+	}
+
+	method := "POST"
+	if !enabled {
+		method = "DELETE"
+	}
+	result, err := p.dnssecRequest(ctx, method, resolved.ID)
+	if err != nil {
+		p.logError(OperationUpdateZone, unFQDN(zone), err)
+		return nil, err
+	}
+
+	p.log(OperationUpdateZone, unFQDN(zone), fmt.Sprintf("done setting DNSSEC of zone %s to %t", unFQDN(zone), enabled))
+
+	if !enabled || result.Digest == "" {
+		return nil, nil
+	}
+	ds := result.dsRecord()
+	return &ds, nil
+}
+
+func (r dnssecResponse) dsRecord() DSRecord {
+	return DSRecord{
+		KeyTag:     r.KeyTag,
+		Algorithm:  r.Algorithm,
+		DigestType: r.DigestType,
+		Digest:     r.Digest,
+		Flags:      r.Flags,
+		PublicKey:  r.PublicKey,
+		Record:     r.DsRecord,
+	}
+}
+
+// dnssecRequest enables (POST) or disables (DELETE) DNSSEC for the zone with
+// the given ID, returning the resulting DNSSEC state.
+func (p *Provider) dnssecRequest(ctx context.Context, method string, zoneID int) (dnssecResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method,
+		fmt.Sprintf("%s/dnszone/%d/dnssec", apiBaseURL, zoneID), nil)
+	if err != nil {
+		return dnssecResponse{}, err
+	}
+
+	data, err := p.doRequest(req)
+	if err != nil {
+		return dnssecResponse{}, err
+	}
+
+	result := dnssecResponse{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &result); err != nil {
+			return dnssecResponse{}, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func Test_DNSSEC(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	enabled, err := p.GetDNSSEC(context.TODO(), "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Fatal("expected DNSSEC to be disabled")
+	}
+
+	ds, err := p.SetDNSSEC(context.TODO(), "example.com.", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds == nil || ds.KeyTag != 12345 || ds.Algorithm != 13 || ds.DigestType != 2 || ds.Digest != "ABCDEF" {
+		t.Fatalf("unexpected DS record => %+v", ds)
+	}
+	if enabled, err := p.GetDNSSEC(context.TODO(), "example.com."); err != nil || !enabled {
+		t.Fatalf("expected DNSSEC to be enabled => %t, %v", enabled, err)
+	}
+
+	ds, err = p.SetDNSSEC(context.TODO(), "example.com.", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds != nil {
+		t.Fatalf("unexpected DS record => %+v", ds)
+	}
+	if zone.DnsSecEnabled {
+		t.Fatal("expected DNSSEC to be disabled")
+	}
+
+	// A name within the zone resolves to the zone, which must be delegated
+	// with CheckDelegation
+	if enabled, err := p.GetDNSSEC(context.TODO(), "www.example.com."); err != nil || enabled {
+		t.Fatalf("expected DNSSEC to be disabled => %t, %v", enabled, err)
+	}
+	p.CheckDelegation = true
+	if _, err := p.SetDNSSEC(context.TODO(), "www.example.com.", true); !errors.Is(err, ErrZoneNotDelegated) {
+		t.Fatalf("expected ErrZoneNotDelegated, got %v", err)
+	}
+	if zone.DnsSecEnabled {
+		t.Fatal("expected DNSSEC to be disabled")
+	}
+}
@@ -0,0 +1,126 @@
+package bunny
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// exportVersion is the version of the format written by ExportRecordsJSON.
+// It is incremented on incompatible changes of the format, so that
+// ImportRecordsJSON can reject exports it doesn't understand.
+const exportVersion = 1
+
+// recordsExport is the format written by ExportRecordsJSON. The records are
+// kept as sent to the API, so that the Bunny.net specific data of records
+// like Redirect and Script records is preserved.
+type recordsExport struct {
+	Version int           `json:"version"`
+	Domain  string        `json:"domain"`
+	Records []bunnyRecord `json:"records"`
+}
+
+// ExportRecordsJSON returns the records of domain as JSON, e.g. for a
+// backup. Unlike the libdns records, the export keeps all the data of the
+// records known to this package, including the Bunny.net specific data of
+// Redirect, PullZone and Script records, so that ImportRecordsJSON can
+// recreate them as they were.
+//
+// domain may also be a name within a zone, in which case only the records at
+// or below it are exported, with their names relative to it. The IDs and the
+// read-only data of the records are left out.
+func (p *Provider) ExportRecordsJSON(ctx context.Context, domain string) ([]byte, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(domain))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return nil, err
+	}
+
+	data, err := p.getZoneRaw(ctx, resolved.ID)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return nil, err
+	}
+
+	zone := struct {
+		Records []bunnyRecord `json:"Records"`
+	}{}
+	if err := json.Unmarshal(data, &zone); err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return nil, err
+	}
+
+	export := recordsExport{Version: exportVersion, Domain: resolved.domain(), Records: []bunnyRecord{}}
+	for _, record := range zone.Records {
+		if resolved.nameBase != "" && !isWithin(record.Name, resolved.nameBase) {
+			continue
+		}
+		record = writableBunnyRecord(record)
+		record.Name = resolved.fromBunnyName(record.Name)
+		export.Records = append(export.Records, record)
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// ImportRecordsJSON creates the records of an export of ExportRecordsJSON in
+// domain, which may differ from the exported domain, and returns the created
+// records. The NS records at the apex of the zone are managed by Bunny.net
+// and are never imported.
+//
+// The records are always created, next to the existing records of domain.
+// Exports of a newer, unknown format version are rejected.
+func (p *Provider) ImportRecordsJSON(ctx context.Context, domain string, data []byte) ([]Record, error) {
+	export := recordsExport{}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid records export: %w", err)
+	}
+	if export.Version < 1 || export.Version > exportVersion {
+		return nil, fmt.Errorf("unsupported records export version %d; expected at most %d", export.Version, exportVersion)
+	}
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(domain))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return nil, err
+	}
+
+	var toImport []bunnyRecord
+	for _, record := range export.Records {
+		if record.Type == bunnyTypeNS && resolved.toBunnyName(record.Name) == "" {
+			continue
+		}
+		toImport = append(toImport, writableBunnyRecord(record))
+	}
+
+	imported := make([][]Record, len(toImport))
+	err = p.forEachBatch(ctx, len(toImport), p.Concurrency, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		record := toImport[i]
+		p.log(OperationCreateRecord, resolved.Domain, fmt.Sprintf("importing %s record %s in zone %s", fromBunnyType(record.Type), record.Name, resolved.Domain))
+
+		err := p.checkCAATag(OperationCreateRecord, resolved.Domain, record)
+		if err == nil {
+			err = p.checkApexCNAME(OperationCreateRecord, resolved, &record)
+		}
+		if err != nil {
+			p.logError(OperationCreateRecord, resolved.Domain, err)
+			return err
+		}
+
+		created, err := p.createBunnyRecord(ctx, resolved, record)
+		if err != nil {
+			p.logError(OperationCreateRecord, resolved.Domain, err)
+			return err
+		}
+		imported[i] = []Record{created}
+		return nil
+	})
+	if err != nil && !p.ContinueOnError {
+		return nil, err
+	}
+
+	return p.outputBunnyRecords(unFQDN(domain), flatten(imported)), err
+}
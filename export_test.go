@@ -0,0 +1,117 @@
+package bunny
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func Test_ExportImportRecordsJSON(t *testing.T) {
+	src := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeNS, Name: "", Value: "kiki.bunny.net", TTL: 3600},
+			{ID: 11, Type: bunnyTypeA, Name: "www", Value: "1.2.3.4", TTL: 120, MonitorStatus: 2},
+			{ID: 12, Type: bunnyTypeRedirect, Name: "go", Value: "https://example.org/go", TTL: 120},
+			{ID: 13, Type: bunnyTypeScript, Name: "edge", TTL: 120, ScriptID: 42, EnvironmentVariables: []bunnyEnvVariable{{Name: "MODE", Value: "strict"}}},
+		},
+	}
+	dst := &mockZone{bunnyZone: bunnyZone{ID: 2, Domain: "example.org"}}
+	newMockAPI(t, src, dst)
+
+	p := &Provider{AccessKey: "key"}
+	data, err := p.ExportRecordsJSON(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export := recordsExport{}
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Version != exportVersion || export.Domain != "example.com" || len(export.Records) != 4 {
+		t.Fatalf("unexpected export => %s", data)
+	}
+	if export.Records[1].ID != 0 || export.Records[1].MonitorStatus != 0 {
+		t.Fatalf("read-only data exported => %+v", export.Records[1])
+	}
+
+	records, err := p.ImportRecordsJSON(context.TODO(), "example.org", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || len(dst.Records) != 3 {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if records[1].Type != "Redirect" || records[1].Value != "https://example.org/go" {
+		t.Fatalf("unexpected Redirect record => %+v", records[1])
+	}
+	script := dst.Records[2]
+	if script.Type != bunnyTypeScript || script.Name != "edge" || script.ScriptID != 42 || len(script.EnvironmentVariables) != 1 {
+		t.Fatalf("unexpected Script record => %+v", script)
+	}
+
+	// a subdomain exports and imports names relative to it
+	data, err = p.ExportRecordsJSON(context.TODO(), "www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err = p.ImportRecordsJSON(context.TODO(), "staging.example.org", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "" || dst.Records[3].Name != "staging" {
+		t.Fatalf("unexpected records => %+v", dst.Records)
+	}
+}
+
+func Test_ImportRecordsJSONVersion(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	for _, data := range []string{
+		`{"records":[{"Type":0,"Name":"www","Value":"1.2.3.4","Ttl":120}]}`,
+		`{"version":2,"records":[{"Type":0,"Name":"www","Value":"1.2.3.4","Ttl":120}]}`,
+		`not json`,
+	} {
+		if _, err := p.ImportRecordsJSON(context.TODO(), "example.com", []byte(data)); err == nil {
+			t.Fatalf("expected an error for %s", data)
+		}
+	}
+	if len(zone.Records) != 0 {
+		t.Fatalf("len(zone.Records) != 0 => %d", len(zone.Records))
+	}
+}
+
+func Test_ImportRecordsJSONValidation(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	data := []byte(`{"version":1,"records":[
+		{"Type":2,"Name":"","Value":"target.example.net","Ttl":120},
+		{"Type":9,"Name":"","Value":"letsencrypt.org","Flags":0,"Tag":"issue-typo","Ttl":120},
+		{"Type":0,"Name":"www","Value":"1.2.3.4","Ttl":120}
+	]}`)
+
+	// The apex CNAME record and the unknown CAA tag are rejected like with
+	// AppendRecords
+	p := &Provider{AccessKey: "key", ContinueOnError: true}
+	records, err := p.ImportRecordsJSON(context.TODO(), "example.com", data)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(records) != 1 || records[0].Name != "www" || len(zone.Records) != 1 {
+		t.Fatalf("unexpected records => %+v", zone.Records)
+	}
+
+	zone.Records = nil
+	p.ApexCNAMEAsFlatten = true
+	p.AllowUnknownCAATags = true
+	if _, err := p.ImportRecordsJSON(context.TODO(), "example.com", data); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 3 || zone.Records[0].Type != bunnyTypeFlatten {
+		t.Fatalf("unexpected records => %+v", zone.Records)
+	}
+}
@@ -0,0 +1,45 @@
+package bunny
+
+import (
+	"context"
+	"net"
+)
+
+// FlattenTarget describes a record of the Bunny.net specific Flatten type,
+// which Bunny.net serves as the A and AAAA records of its target, like a
+// CNAME record that is allowed at the apex of the zone.
+type FlattenTarget struct {
+	// Record is the Flatten record.
+	Record Record
+	// Target is the hostname the record is flattened to.
+	Target string
+
+	// Addresses are the addresses the target currently resolves to, if a
+	// resolver was given. ResolveErr is set if resolving the target failed.
+	Addresses  []net.IP
+	ResolveErr error
+}
+
+// GetFlattenTargets returns the Flatten records of the zone with their
+// targets, e.g. to verify that the apex of the zone is flattened to the
+// right hostname. The API does not expose the addresses Bunny.net resolved
+// the targets to, so if resolver is not empty, the targets are resolved by
+// querying resolver, given as "host" or "host:port". A target that can't be
+// resolved does not fail the call, but sets ResolveErr.
+func (p *Provider) GetFlattenTargets(ctx context.Context, zone, resolver string) ([]FlattenTarget, error) {
+	records, err := p.GetRecordsByBunnyType(ctx, zone, bunnyTypeFlatten)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]FlattenTarget, 0, len(records))
+	for _, record := range records {
+		target := FlattenTarget{Record: record, Target: unFQDN(record.Value)}
+		if resolver != "" {
+			target.Addresses, target.ResolveErr = newResolver(resolver).LookupIP(ctx, "ip", target.Target+".")
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,38 @@
+package bunny
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_GetFlattenTargets(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeFlatten, Name: "", Value: "target.example.net", TTL: 120},
+			{ID: 11, Type: bunnyTypeCNAME, Name: "www", Value: "target.example.net", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	targets, err := p.GetFlattenTargets(context.TODO(), "example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Record.ID != "10" || targets[0].Target != "target.example.net" {
+		t.Fatalf("unexpected targets => %+v", targets)
+	}
+	if targets[0].Addresses != nil || targets[0].ResolveErr != nil {
+		t.Fatalf("unexpected resolution without resolver => %+v", targets[0])
+	}
+
+	// A target that can't be resolved is still returned
+	resolver := serveTestDNS(t, func() string { return "" })
+	targets, err = p.GetFlattenTargets(context.TODO(), "example.com", resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].ResolveErr == nil {
+		t.Fatalf("expected a resolve error => %+v", targets)
+	}
+}
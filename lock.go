@@ -0,0 +1,95 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// nameLock is the lock of a record name, shared by the writers of that name.
+type nameLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// lockNames locks the names of records in the zone, so that concurrent
+// writes to the same name are serialized: a SetRecords call only looks at
+// the stored records once the calls before it for the same names are done,
+// and so sees the records they created, rather than creating them again. The
+// names are locked in order, so that calls for overlapping names can't
+// deadlock. The returned function unlocks them. A lock that is still
+// waited for is given up if ctx is done.
+func (p *Provider) lockNames(ctx context.Context, zone bunnyZone, records []libdns.Record) (func(), error) {
+	var keys []string
+	seen := map[string]bool{}
+	for _, record := range records {
+		key := fmt.Sprintf("%d %s", zone.ID, strings.ToLower(zone.toBunnyName(record.Name)))
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var locked []string
+	unlock := func() {
+		for _, key := range locked {
+			p.unlockName(key)
+		}
+	}
+	for _, key := range keys {
+		if err := p.lockName(ctx, key); err != nil {
+			unlock()
+			return nil, err
+		}
+		locked = append(locked, key)
+	}
+
+	return unlock, nil
+}
+
+func (p *Provider) lockName(ctx context.Context, key string) error {
+	p.mu.Lock()
+	if p.nameLocks == nil {
+		p.nameLocks = map[string]*nameLock{}
+	}
+	lock := p.nameLocks[key]
+	if lock == nil {
+		lock = &nameLock{ch: make(chan struct{}, 1)}
+		p.nameLocks[key] = lock
+	}
+	lock.refs++
+	p.mu.Unlock()
+
+	select {
+	case lock.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		p.releaseName(key, lock)
+		return ctx.Err()
+	}
+}
+
+func (p *Provider) unlockName(key string) {
+	p.mu.Lock()
+	lock := p.nameLocks[key]
+	p.mu.Unlock()
+
+	<-lock.ch
+	p.releaseName(key, lock)
+}
+
+// releaseName drops a reference to the lock of a name, removing the lock
+// once nobody holds or waits for it.
+func (p *Provider) releaseName(key string, lock *nameLock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(p.nameLocks, key)
+	}
+}
@@ -0,0 +1,65 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_SetRecordsConcurrently(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+				{Type: "TXT", Name: "test", Value: fmt.Sprintf("test%d", i), TTL: ttl},
+				{Type: "A", Name: fmt.Sprintf("www%d", i%2), Value: "1.2.3.4", TTL: ttl},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(zone.Records) != 3 {
+		t.Fatalf("len(zone.Records) != 3 => %+v", zone.Records)
+	}
+	if len(p.nameLocks) != 0 {
+		t.Fatalf("len(p.nameLocks) != 0 => %d", len(p.nameLocks))
+	}
+}
+
+func Test_lockNamesContext(t *testing.T) {
+	zone := bunnyZone{ID: 1, Domain: "example.com"}
+	records := []libdns.Record{{Type: "TXT", Name: "test"}}
+
+	p := &Provider{}
+	unlock, err := p.lockNames(context.TODO(), zone, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if _, err := p.lockNames(ctx, zone, records); err != context.Canceled {
+		t.Fatalf("err != context.Canceled => %v", err)
+	}
+
+	unlock()
+	if len(p.nameLocks) != 0 {
+		t.Fatalf("len(p.nameLocks) != 0 => %d", len(p.nameLocks))
+	}
+}
@@ -0,0 +1,101 @@
+package bunny
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// Operations reported in LogEvent.Operation.
+const (
+	OperationGetZone      = "get_zone"
+	OperationListZones    = "list_zones"
+	OperationUpdateZone   = "update_zone"
+	OperationGetRecords   = "get_records"
+	OperationCreateRecord = "create_record"
+	OperationUpdateRecord = "update_record"
+	OperationDeleteRecord = "delete_record"
+	// OperationRequest is a single request sent to the API, as part of one
+	// of the other operations.
+	OperationRequest = "request"
+)
+
+// LogEvent describes a step of an operation performed by the provider.
+type LogEvent struct {
+	// Operation is one of the Operation* constants, or empty for warnings
+	// about the configuration of the provider.
+	Operation string
+	// Zone is the zone (or domain) the operation was performed on. It is
+	// empty for OperationRequest events.
+	Zone string
+	// Message is the human readable message, as passed to Logger.
+	Message string
+	// Records are the records involved in the operation, if any.
+	Records []libdns.Record
+	// Err is set if the operation failed.
+	Err error
+
+	// Method, Path, StatusCode and Duration describe the request of an
+	// OperationRequest event: its HTTP method, the URL path without query,
+	// the status code of the response, or 0 if there is none, and the time
+	// it took until the response headers were received.
+	Method     string
+	Path       string
+	StatusCode int
+	Duration   time.Duration
+}
+
+func (p *Provider) log(op, zone, msg string, records ...libdns.Record) {
+	p.logEvent(LogEvent{Operation: op, Zone: zone, Message: msg, Records: records})
+}
+
+func (p *Provider) logError(op, zone string, err error, records ...libdns.Record) {
+	p.logEvent(LogEvent{Operation: op, Zone: zone, Message: fmt.Sprintf("%s failed in zone %s: %v", op, zone, err), Records: records, Err: err})
+}
+
+// logRequest logs a request sent to the API, which took duration.
+func (p *Provider) logRequest(request *http.Request, response *http.Response, duration time.Duration, err error) {
+	event := LogEvent{
+		Operation: OperationRequest,
+		Method:    request.Method,
+		Path:      request.URL.Path,
+		Duration:  duration,
+		Err:       err,
+	}
+	if response != nil {
+		event.StatusCode = response.StatusCode
+	}
+
+	if err != nil {
+		event.Message = fmt.Sprintf("%s %s failed after %s: %v", event.Method, event.Path, duration.Round(time.Millisecond), err)
+	} else {
+		event.Message = fmt.Sprintf("%s %s: %d in %s", event.Method, event.Path, event.StatusCode, duration.Round(time.Millisecond))
+	}
+	p.logEvent(event)
+}
+
+func (p *Provider) logEvent(event LogEvent) {
+	if p.EventLogger != nil {
+		p.EventLogger(event)
+	} else if p.Logger != nil {
+		p.Logger(event.Message, event.Records)
+	} else if p.Debug {
+		fmt.Printf("[bunny] %s\n", event.Message)
+		for _, record := range event.Records {
+			var id string
+			if record.ID == "" {
+				id = "(new)"
+			} else {
+				id = record.ID
+			}
+			ttl := record.TTL.String()
+			if record.TTL == TTLAutomatic {
+				ttl = "automatic"
+			}
+			fmt.Printf("[bunny]   %s: ID=%s, TTL=%s, Priority=%d, Name=%s, Value=%s\n",
+				record.Type, id, ttl, record.Priority, record.Name, record.Value)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package bunny
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_EventLogger(t *testing.T) {
+	newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	var events []LogEvent
+	p := &Provider{
+		AccessKey:   "key",
+		EventLogger: func(e LogEvent) { events = append(events, e) },
+	}
+
+	if _, err := p.GetRecords(context.TODO(), "unknown.org"); err == nil {
+		t.Fatal("expected an error for an unknown zone")
+	}
+
+	last := events[len(events)-1]
+	if last.Operation != OperationGetRecords {
+		t.Fatalf("last.Operation != OperationGetRecords => %s != %s", last.Operation, OperationGetRecords)
+	}
+	if last.Zone != "unknown.org" {
+		t.Fatalf(`last.Zone != "unknown.org" => %s != "unknown.org"`, last.Zone)
+	}
+	if last.Err == nil {
+		t.Fatal("last.Err == nil")
+	}
+}
+
+func Test_LoggerAdapter(t *testing.T) {
+	newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	var messages []string
+	p := &Provider{
+		AccessKey: "key",
+		Logger:    func(msg string, _ []libdns.Record) { messages = append(messages, msg) },
+	}
+
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) == 0 {
+		t.Fatal("len(messages) == 0")
+	}
+	if messages[0] != "fetching all records for example.com" {
+		t.Fatalf(`messages[0] != "fetching all records for example.com" => %s`, messages[0])
+	}
+}
+
+func Test_RequestEvents(t *testing.T) {
+	newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	var events []LogEvent
+	p := &Provider{
+		AccessKey:   "key",
+		EventLogger: func(e LogEvent) { events = append(events, e) },
+	}
+
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []LogEvent
+	for _, event := range events {
+		if event.Operation == OperationRequest {
+			requests = append(requests, event)
+		}
+	}
+	if len(requests) != 2 {
+		t.Fatalf("len(requests) != 2 => %d", len(requests))
+	}
+	last := requests[1]
+	if last.Method != "GET" || last.Path != "/dnszone/1" || last.StatusCode != 200 || last.Duration <= 0 {
+		t.Fatalf("unexpected request event => %+v", last)
+	}
+	if !strings.HasPrefix(last.Message, "GET /dnszone/1: 200 in ") {
+		t.Fatalf("unexpected message => %s", last.Message)
+	}
+}
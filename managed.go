@@ -0,0 +1,127 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/libdns/libdns"
+)
+
+// SyncResult lists the changes made by SyncManagedRecords.
+type SyncResult struct {
+	// Created are the desired records that did not exist yet.
+	Created []libdns.Record
+	// Updated are the desired records that replaced a managed record.
+	Updated []libdns.Record
+	// Deleted are the managed records that were not desired.
+	Deleted []libdns.Record
+}
+
+// SyncManagedRecords makes the records of the zone that managedFilter
+// reports as managed, e.g. by their name or by a marker in their value, be
+// exactly the desired records, like DiffRecords computes it: records are
+// created, updated and deleted as needed. Records that are not managed are
+// never changed, so that records created elsewhere are kept. A nil
+// managedFilter manages all records of the zone.
+//
+// Every desired record must be managed itself, as it would otherwise be
+// created again by every sync. The NS records at the apex of the zone are
+// managed by Bunny.net and never deleted.
+func (p *Provider) SyncManagedRecords(ctx context.Context, zone string, desired []libdns.Record, managedFilter func(libdns.Record) bool) (SyncResult, error) {
+	if managedFilter == nil {
+		managedFilter = func(libdns.Record) bool { return true }
+	}
+	for _, record := range desired {
+		if !managedFilter(record) {
+			return SyncResult{}, fmt.Errorf("desired %s record %s is not managed", record.Type, record.Name)
+		}
+	}
+	if err := validateCNAMEConflicts(unFQDN(zone), desired); err != nil {
+		return SyncResult{}, err
+	}
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return SyncResult{}, err
+	}
+
+	// the names are locked as by SetRecords, so that a concurrent write
+	// of a desired name is seen rather than duplicated
+	unlock, err := p.lockNames(ctx, resolved, desired)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	defer unlock()
+
+	existing, err := p.getZoneRecords(ctx, resolved)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return SyncResult{}, err
+	}
+
+	var managed []Record
+	for _, record := range existing {
+		if managedFilter(record.Record) {
+			managed = append(managed, record)
+		}
+	}
+
+	toCreate, toUpdate, toDelete := p.diffRecords(resolved, desired, managed)
+
+	result := SyncResult{}
+
+	created := make([][]libdns.Record, len(toCreate))
+	createErr := p.forEachBatch(ctx, len(toCreate), p.Concurrency, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		record, err := p.createRecord(ctx, resolved, toCreate[i])
+		if err != nil {
+			p.logError(OperationCreateRecord, resolved.Domain, err, toCreate[i])
+			return err
+		}
+		created[i] = []libdns.Record{record.Record}
+		return nil
+	})
+	result.Created = p.outputRecords(unFQDN(zone), flatten(created))
+	if createErr != nil && !p.ContinueOnError {
+		return result, createErr
+	}
+
+	updated := make([][]libdns.Record, len(toUpdate))
+	updateErr := p.forEachBatch(ctx, len(toUpdate), 1, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		if err := p.updateRecord(ctx, resolved, toUpdate[i], storedRecord(existing, toUpdate[i].ID)); err != nil {
+			p.logError(OperationUpdateRecord, resolved.Domain, err, toUpdate[i])
+			return err
+		}
+		updated[i] = []libdns.Record{toUpdate[i]}
+		return nil
+	})
+	result.Updated = p.outputRecords(unFQDN(zone), flatten(updated))
+	if updateErr != nil && !p.ContinueOnError {
+		return result, updateErr
+	}
+
+	// The records are deleted last, so that a name being changed never
+	// resolves to no record in between.
+	deleted := make([][]libdns.Record, len(toDelete))
+	deleteErr := p.forEachBatch(ctx, len(toDelete), 1, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		if err := p.deleteRecord(ctx, resolved, toDelete[i]); err != nil {
+			p.logError(OperationDeleteRecord, resolved.Domain, err, toDelete[i])
+			return err
+		}
+		deleted[i] = []libdns.Record{toDelete[i]}
+		return nil
+	})
+	result.Deleted = p.outputRecords(unFQDN(zone), flatten(deleted))
+
+	return result, errors.Join(createErr, updateErr, deleteErr)
+}
@@ -0,0 +1,110 @@
+package bunny
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_SyncManagedRecords(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 9, Type: bunnyTypeNS, Name: "", Value: "kiki.bunny.net", TTL: 120},
+			{ID: 10, Type: bunnyTypeA, Name: "app", Value: "192.0.2.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "app", Value: "192.0.2.2", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "app", Value: "old", TTL: 120},
+			{ID: 13, Type: bunnyTypeA, Name: "www", Value: "192.0.2.9", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone)
+
+	managed := func(record libdns.Record) bool {
+		return strings.HasPrefix(record.Name, "app")
+	}
+
+	p := &Provider{AccessKey: "key"}
+	result, err := p.SyncManagedRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "app", Value: "192.0.2.1", TTL: ttl},
+		{Type: "A", Name: "app", Value: "192.0.2.3", TTL: ttl},
+		{Type: "CNAME", Name: "app-api", Value: "app.example.com.", TTL: ttl},
+	}, managed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Created) != 1 || result.Created[0].Name != "app-api" {
+		t.Fatalf("unexpected result.Created => %+v", result.Created)
+	}
+	if len(result.Updated) != 1 || result.Updated[0].ID != "11" || result.Updated[0].Value != "192.0.2.3" {
+		t.Fatalf("unexpected result.Updated => %+v", result.Updated)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0].ID != "12" {
+		t.Fatalf("unexpected result.Deleted => %+v", result.Deleted)
+	}
+
+	var ids []int
+	for _, record := range zone.Records {
+		ids = append(ids, record.ID)
+	}
+	if len(ids) != 5 || ids[0] != 9 || ids[3] != 13 {
+		t.Fatalf("unmanaged records changed => %v", ids)
+	}
+
+	// syncing again changes nothing
+	result, err = p.SyncManagedRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "app", Value: "192.0.2.1", TTL: ttl},
+		{Type: "A", Name: "app", Value: "192.0.2.3", TTL: ttl},
+		{Type: "CNAME", Name: "app-api", Value: "app.example.com.", TTL: ttl},
+	}, managed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Created)+len(result.Updated)+len(result.Deleted) != 0 {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+
+	// desired records must be managed
+	_, err = p.SyncManagedRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1", TTL: ttl},
+	}, managed)
+	if err == nil {
+		t.Fatal("expected an error for an unmanaged desired record")
+	}
+	if zone.Records[3].Value != "192.0.2.9" {
+		t.Fatalf("unexpected zone record => %+v", zone.Records[3])
+	}
+}
+
+func Test_SyncManagedRecordsConcurrently(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	desired := []libdns.Record{{Type: "A", Name: "app", Value: "192.0.2.1", TTL: ttl}}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = p.SyncManagedRecords(context.TODO(), "example.com", desired, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(zone.Records) != 1 {
+		t.Fatalf("len(zone.Records) != 1 => %+v", zone.Records)
+	}
+	if len(p.nameLocks) != 0 {
+		t.Fatalf("len(p.nameLocks) != 0 => %d", len(p.nameLocks))
+	}
+}
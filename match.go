@@ -0,0 +1,326 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// recordMatches reports whether candidate is the record described by want.
+// Records are identified by their name, type and, if want has one, value;
+// the TTL is never compared, as callers rarely know the stored TTL.
+func recordMatches(zone string, candidate, want libdns.Record) bool {
+	if !strings.EqualFold(candidate.Type, want.Type) {
+		return false
+	}
+	if normalizeName(candidate.Name, zone) != normalizeName(want.Name, zone) {
+		return false
+	}
+	// the preference of an MX value of the form "<preference> <target>", and
+	// the priority and weight of an SRV value in its zone file form, are part
+	// of the value
+	if fromValue, ok := splitRRValue(want); ok {
+		if candidate.Priority != fromValue.Priority || candidate.Weight != fromValue.Weight {
+			return false
+		}
+		want = fromValue
+	}
+	if want.Value != "" && !sameValue(want.Type, candidate.Value, want.Value) {
+		return false
+	}
+	return true
+}
+
+// sameRecord reports whether a and b describe the same record: the same
+// name, type and data, including the type-specific fields. The TTL is not
+// compared.
+func sameRecord(zone string, a, b libdns.Record) bool {
+	return recordKey(zone, a) == recordKey(zone, b)
+}
+
+// isUnchanged reports whether updating stored with record would not change
+// it: they are the same record with the same TTL. A record without TTL has
+// the TTL of stored, unless DefaultTTL is set.
+func (p *Provider) isUnchanged(zone string, stored, record libdns.Record) bool {
+	if !sameRecord(zone, stored, record) {
+		return false
+	}
+	return p.ttlMatches(record.TTL, stored.TTL)
+}
+
+// RecordKey returns a canonical identity of the record, made of its type,
+// name and data, including the type-specific fields, like the priority of an
+// MX record. Records with the same key are the same record, regardless of
+// their ID and TTL, the case of their name and, for hostnames, of their
+// value. The name is expected to be relative to the zone.
+//
+// Keys are meant to be compared, e.g. as keys of a map; their format may
+// change.
+func RecordKey(record libdns.Record) string {
+	return recordKey("", record)
+}
+
+// recordKey returns the key of record, with its name relative to zone.
+func recordKey(zone string, record libdns.Record) string {
+	name := normalizeName(record.Name, zone)
+	if name == "" {
+		name = "@"
+	}
+	recordType := strings.ToUpper(record.Type)
+
+	if fromValue, ok := splitRRValue(record); ok && (record.Priority == 0 || record.Priority == fromValue.Priority) && (record.Weight == 0 || record.Weight == fromValue.Weight) {
+		record = fromValue
+	}
+	value := normalizeValue(recordType, record.Value)
+
+	switch recordType {
+	case "A", "AAAA":
+		if record.Weight != 0 {
+			return fmt.Sprintf("%s %s %s weight=%d", name, recordType, value, record.Weight)
+		}
+	case "MX":
+		return fmt.Sprintf("%s %s %d %s", name, recordType, record.Priority, value)
+	case "SRV":
+		return fmt.Sprintf("%s %s %d %d %s", name, recordType, record.Priority, record.Weight, value)
+	}
+	return fmt.Sprintf("%s %s %s", name, recordType, value)
+}
+
+// sameValue reports whether a and b are the same value of a record of the
+// given type, see normalizeValue.
+func sameValue(recordType, a, b string) bool {
+	return normalizeValue(recordType, a) == normalizeValue(recordType, b)
+}
+
+// normalizeValue returns the canonical form of a value of a record of the
+// given type. Hostnames are compared case-insensitively, as the API may
+// normalize their case, while any other value, like the content of a TXT
+// record, is case-sensitive.
+func normalizeValue(recordType, value string) string {
+	switch strings.ToUpper(recordType) {
+	case "CNAME", "MX", "NS", "PTR", "SRV":
+		return strings.ToLower(unFQDN(value))
+	}
+	return value
+}
+
+// matchingRecords returns the records of existing that match want, see
+// recordMatches.
+func matchingRecords(zone string, want libdns.Record, existing []Record) []libdns.Record {
+	var matches []libdns.Record
+	for _, record := range existing {
+		if recordMatches(zone, record.Record, want) {
+			matches = append(matches, record.Record)
+		}
+	}
+	return matches
+}
+
+// findExistingRecords finds the stored records that a record without ID
+// sets: the identical record if there is exactly one, or else the record
+// with the same name, type and value if there is exactly one, or else the
+// record with the same name, type and weight if there is exactly one, or
+// else all the records with the same name and type. This pairs each record
+// of a set of weighted records with its counterpart, if only its weight or
+// its value changes.
+func findExistingRecords(zone string, record libdns.Record, existing []Record) []libdns.Record {
+	var identical, withValue, withWeight, sameNameAndType []libdns.Record
+	for _, candidate := range existing {
+		if !strings.EqualFold(candidate.Type, record.Type) || normalizeName(candidate.Name, zone) != normalizeName(record.Name, zone) {
+			continue
+		}
+		sameNameAndType = append(sameNameAndType, candidate.Record)
+		if sameRecord(zone, candidate.Record, record) {
+			identical = append(identical, candidate.Record)
+		}
+		if sameValue(record.Type, candidate.Value, record.Value) {
+			withValue = append(withValue, candidate.Record)
+		}
+		if record.Weight != 0 && candidate.Weight == record.Weight {
+			withWeight = append(withWeight, candidate.Record)
+		}
+	}
+
+	switch {
+	case len(identical) == 1:
+		return identical
+	case len(withValue) == 1:
+		return withValue
+	case len(withWeight) == 1:
+		return withWeight
+	}
+	return sameNameAndType
+}
+
+// isTXTRecord reports whether record is a TXT record. Identical TXT records,
+// like the ones left by an ACME solver that presented a challenge twice, are
+// never created twice by AppendRecords and deleted together by DeleteRecords.
+func isTXTRecord(record libdns.Record) bool {
+	return strings.EqualFold(record.Type, "TXT")
+}
+
+// hasTXTRecord reports whether any of records is a TXT record.
+func hasTXTRecord(records []libdns.Record) bool {
+	for _, record := range records {
+		if isTXTRecord(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// txtDuplicates returns the records of existing other than the TXT record
+// with the ID of record that are identical to it. The data of the stored
+// record is used, as record may only have its ID and type.
+func txtDuplicates(zone string, record libdns.Record, existing []Record) []libdns.Record {
+	stored := record
+	for _, candidate := range existing {
+		if candidate.ID == record.ID {
+			stored = candidate.Record
+			break
+		}
+	}
+
+	var duplicates []libdns.Record
+	for _, candidate := range existing {
+		if candidate.ID != record.ID && sameRecord(zone, candidate.Record, stored) {
+			duplicates = append(duplicates, candidate.Record)
+		}
+	}
+	return duplicates
+}
+
+// CreateRecordIfAbsent creates record in the zone, unless the zone already
+// has the same record (see sameRecord), e.g. an ACME challenge presented by
+// a concurrent solver. It returns the created or existing record and whether
+// it was created.
+//
+// If the creation fails, the zone is checked again, so that a record created
+// concurrently in the meantime is returned instead of the error.
+func (p *Provider) CreateRecordIfAbsent(ctx context.Context, zone string, record libdns.Record) (libdns.Record, bool, error) {
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return libdns.Record{}, false, err
+	}
+
+	existing, err := p.findSameRecord(ctx, resolved, record)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return libdns.Record{}, false, err
+	}
+	if existing != nil {
+		p.log(OperationCreateRecord, resolved.Domain, fmt.Sprintf("skipping creation of existing %s record %s in zone %s", existing.Type, existing.ID, resolved.Domain), *existing)
+		existing.Name = p.outputName(unFQDN(zone), existing.Name)
+		return *existing, false, nil
+	}
+
+	created, createErr := p.createRecord(ctx, resolved, record)
+	if createErr == nil {
+		created.Name = p.outputName(unFQDN(zone), created.Name)
+		return created.Record, true, nil
+	}
+
+	existing, err = p.findSameRecord(ctx, resolved, record)
+	if err == nil && existing != nil {
+		p.log(OperationCreateRecord, resolved.Domain, fmt.Sprintf("%s record %s was created concurrently in zone %s", existing.Type, existing.ID, resolved.Domain), *existing)
+		existing.Name = p.outputName(unFQDN(zone), existing.Name)
+		return *existing, false, nil
+	}
+
+	p.logError(OperationCreateRecord, resolved.Domain, createErr, record)
+	return libdns.Record{}, false, createErr
+}
+
+// ReplaceRecord replaces the record of the zone that matches old with
+// newRecord, updating it in place, so that it keeps its ID and its
+// Bunny.net specific data, and there is no gap in which neither record is
+// served. old matches the stored record with its ID, if set, or else the
+// same record (see RecordKey), or, if old has no value, the record with its
+// name and type. If no record or more than one record matches old, the zone
+// is not changed; ErrRecordNotFound is returned if there is none.
+func (p *Provider) ReplaceRecord(ctx context.Context, zone string, old, newRecord libdns.Record) (libdns.Record, error) {
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return libdns.Record{}, err
+	}
+
+	records, err := p.getZoneRecords(ctx, resolved)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return libdns.Record{}, err
+	}
+
+	var matches []libdns.Record
+	for _, record := range records {
+		var match bool
+		switch {
+		case old.ID != "":
+			match = record.ID == old.ID
+		case old.Value != "":
+			match = sameRecord(resolved.domain(), record.Record, old)
+		default:
+			match = recordMatches(resolved.domain(), record.Record, old)
+		}
+		if match {
+			matches = append(matches, record.Record)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return libdns.Record{}, fmt.Errorf("%w: %s record %s in zone %s", ErrRecordNotFound, old.Type, old.Name, resolved.Domain)
+	case 1:
+	default:
+		return libdns.Record{}, fmt.Errorf("unexpectedly found more than 1 %s record for %s in zone %s", old.Type, old.Name, resolved.Domain)
+	}
+
+	newRecord.ID = matches[0].ID
+	if err := p.updateRecord(ctx, resolved, newRecord, storedRecord(records, newRecord.ID)); err != nil {
+		p.logError(OperationUpdateRecord, resolved.Domain, err, newRecord)
+		return libdns.Record{}, err
+	}
+
+	newRecord.Name = p.outputName(unFQDN(zone), newRecord.Name)
+	return newRecord, nil
+}
+
+// RecordExists reports whether the zone has the same record as record, see
+// RecordKey: a record with its name, type and data, including the
+// type-specific fields, like the priority of an MX record. The ID and the TTL
+// of record are ignored. Of several TXT records with the same name, e.g. ACME
+// challenges, only the one with the value of record matches.
+func (p *Provider) RecordExists(ctx context.Context, zone string, record libdns.Record) (bool, error) {
+	domain := unFQDN(zone)
+	found := false
+	_, err := p.eachRecord(ctx, domain, func(candidate Record) bool {
+		found = sameRecord(domain, candidate.Record, record)
+		return !found
+	})
+	if err != nil {
+		p.logError(OperationGetRecords, domain, err)
+		return false, err
+	}
+
+	return found, nil
+}
+
+// findSameRecord returns the record stored in the zone that is the same as
+// want, see sameRecord, or nil if there is none.
+func (p *Provider) findSameRecord(ctx context.Context, zone bunnyZone, want libdns.Record) (*libdns.Record, error) {
+	records, err := p.getZoneRecords(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if sameRecord(zone.domain(), record.Record, want) {
+			return &record.Record, nil
+		}
+	}
+
+	return nil, nil
+}
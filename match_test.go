@@ -0,0 +1,668 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_recordMatches(t *testing.T) {
+	stored := libdns.Record{ID: "10", Type: "TXT", Name: "test", Value: "test", TTL: 300 * ttl}
+
+	testCases := []struct {
+		want     libdns.Record
+		expected bool
+	}{
+		{libdns.Record{Type: "TXT", Name: "test", Value: "test", TTL: ttl}, true},
+		{libdns.Record{Type: "TXT", Name: "test.example.com.", Value: "test"}, true},
+		{libdns.Record{Type: "TXT", Name: "TEST"}, true},
+		{libdns.Record{Type: "TXT", Name: "test", Value: "other"}, false},
+		{libdns.Record{Type: "A", Name: "test", Value: "test"}, false},
+		{libdns.Record{Type: "TXT", Name: "other", Value: "test"}, false},
+	}
+
+	for k, c := range testCases {
+		if result := recordMatches("example.com", stored, c.want); result != c.expected {
+			t.Fatalf("testCases[%d]: recordMatches != c.expected => %t != %t", k, result, c.expected)
+		}
+	}
+}
+
+func Test_DeleteRecordsWithoutID(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "keep", TTL: 3600},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "delete", TTL: 3600},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	// the TTL differs from the stored one
+	deleted, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "test", Value: "delete", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 1 || deleted[0].ID != "11" {
+		t.Fatalf("unexpected deleted records => %+v", deleted)
+	}
+	if len(zone.Records) != 1 || zone.Records[0].ID != 10 {
+		t.Fatalf("unexpected remaining records => %+v", zone.Records)
+	}
+}
+
+func Test_DeleteRecordsRequests(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "a", Value: "a", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "b", Value: "b", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "c", Value: "c", TTL: 120},
+			{ID: 13, Type: bunnyTypeTXT, Name: "d", Value: "d", TTL: 120},
+		},
+	}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	deleted, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "a"},
+		{Type: "TXT", Name: "b"},
+		{Type: "TXT", Name: "c", Value: "c"},
+		// already deleted by its name
+		{Type: "TXT", Name: "a", Value: "a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 3 {
+		t.Fatalf("len(deleted) != 3 => %d", len(deleted))
+	}
+
+	// one zone lookup, one fetch of the records and one request per record
+	if count := m.requestCount("GET"); count != 2 {
+		t.Fatalf("m.requestCount(\"GET\") != 2 => %d", count)
+	}
+	if count := m.requestCount("DELETE"); count != 3 {
+		t.Fatalf("m.requestCount(\"DELETE\") != 3 => %d", count)
+	}
+	if len(zone.Records) != 1 || zone.Records[0].ID != 13 {
+		t.Fatalf("unexpected remaining records => %+v", zone.Records)
+	}
+}
+
+func Test_ACMEPresentTwice(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	challenge := []libdns.Record{{Type: "TXT", Name: "_acme-challenge", Value: "token", TTL: ttl}}
+
+	first, err := p.AppendRecords(context.TODO(), "example.com", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.AppendRecords(context.TODO(), "example.com", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 1 || len(second) != 1 || second[0].ID != first[0].ID {
+		t.Fatalf("unexpected records => %+v", zone.Records)
+	}
+
+	// duplicates that slipped through are cleaned up with the presented record
+	zone.Records = append(zone.Records,
+		bunnyRecord{ID: 20, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token", TTL: 120},
+		bunnyRecord{ID: 21, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "other", TTL: 120},
+	)
+	deleted, err := p.DeleteRecords(context.TODO(), "example.com", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0].ID != first[0].ID || deleted[1].ID != "20" {
+		t.Fatalf("unexpected deleted records => %+v", deleted)
+	}
+	if len(zone.Records) != 1 || zone.Records[0].ID != 21 {
+		t.Fatalf("unexpected remaining records => %+v", zone.Records)
+	}
+}
+
+func Test_IdempotentAppend(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key", IdempotentAppend: true}
+	records := []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge", Value: "token", TTL: ttl},
+		{Type: "MX", Name: "", Value: "mail.example.com", Priority: 10, TTL: ttl},
+	}
+
+	first, err := p.AppendRecords(context.TODO(), "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a different MX priority is a different record
+	records = append(records, libdns.Record{Type: "MX", Name: "", Value: "mail.example.com", Priority: 20, TTL: ttl})
+	second, err := p.AppendRecords(context.TODO(), "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(zone.Records) != 3 {
+		t.Fatalf("len(zone.Records) != 3 => %d", len(zone.Records))
+	}
+	for k := range first {
+		if first[k].ID != second[k].ID {
+			t.Fatalf("first[%d].ID != second[%d].ID => %s != %s", k, k, first[k].ID, second[k].ID)
+		}
+	}
+}
+
+func Test_SetRecordsTTLOnlyChange(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120, Flags: 1},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	result, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "127.0.0.1", TTL: 10 * ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0].ID != "10" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+	if len(zone.Records) != 1 {
+		t.Fatalf("len(zone.Records) != 1 => %d", len(zone.Records))
+	}
+	stored := zone.Records[0]
+	if stored.ID != 10 || stored.TTL != 1200 || stored.Flags != 1 {
+		t.Fatalf("unexpected stored record => %+v", stored)
+	}
+}
+
+func Test_SetRecordsUnchanged(t *testing.T) {
+	m := newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "a", TTL: 120},
+			{ID: 11, Type: bunnyTypeMX, Name: "", Value: "mail.example.com", Priority: 10, TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	result, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "test", Value: "a", TTL: ttl},
+		{ID: "11", Type: "MX", Name: "@", Value: "mail.example.com.", Priority: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].ID != "10" || result[1].ID != "11" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+	for _, method := range []string{"PUT", "POST", "DELETE"} {
+		if count := m.requestCount(method); count != 0 {
+			t.Fatalf("m.requestCount(%q) != 0 => %d", method, count)
+		}
+	}
+
+	// a changed type-specific field is written
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{ID: "11", Type: "MX", Name: "", Value: "mail.example.com", Priority: 20, TTL: ttl},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count := m.requestCount("POST"); count != 1 {
+		t.Fatalf("m.requestCount(\"POST\") != 1 => %d", count)
+	}
+}
+
+func Test_SetRecordsWeighted(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "lb", Value: "192.0.2.1", Weight: 10, TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "lb", Value: "192.0.2.2", Weight: 20, TTL: 120},
+			{ID: 12, Type: bunnyTypeA, Name: "lb", Value: "192.0.2.3", Weight: 30, TTL: 120},
+		},
+	}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	result, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		// the weight changes
+		{Type: "A", Name: "lb", Value: "192.0.2.1", Weight: 50, TTL: ttl},
+		// unchanged
+		{Type: "A", Name: "lb", Value: "192.0.2.2", Weight: 20, TTL: ttl},
+		// the value changes
+		{Type: "A", Name: "lb", Value: "192.0.2.4", Weight: 30, TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 3 || result[0].ID != "10" || result[1].ID != "11" || result[2].ID != "12" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+	if count := m.requestCount("POST"); count != 2 {
+		t.Fatalf("m.requestCount(\"POST\") != 2 => %d", count)
+	}
+
+	want := []bunnyRecord{
+		{ID: 10, Value: "192.0.2.1", Weight: 50},
+		{ID: 11, Value: "192.0.2.2", Weight: 20},
+		{ID: 12, Value: "192.0.2.4", Weight: 30},
+	}
+	if len(zone.Records) != len(want) {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+	for i, w := range want {
+		got := zone.Records[i]
+		if got.ID != w.ID || got.Value != w.Value || got.Weight != w.Weight {
+			t.Fatalf("zone.Records[%d] != %+v => %+v", i, w, got)
+		}
+	}
+
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].Weight != 50 {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+
+	// the records are paired by weight, whatever their order
+	zone.Records = []bunnyRecord{
+		{ID: 10, Type: bunnyTypeA, Name: "lb", Value: "192.0.2.1", Weight: 10, TTL: 120, MonitorType: 1},
+		{ID: 11, Type: bunnyTypeA, Name: "lb", Value: "192.0.2.2", Weight: 20, TTL: 120},
+		{ID: 12, Type: bunnyTypeA, Name: "lb", Value: "192.0.2.3", Weight: 30, TTL: 120, MonitorType: 2},
+	}
+	result, err = p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "lb", Value: "192.0.2.2", Weight: 20, TTL: ttl},
+		{Type: "A", Name: "lb", Value: "192.0.2.9", Weight: 30, TTL: ttl},
+		{Type: "A", Name: "lb", Value: "192.0.2.8", Weight: 10, TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 3 || result[0].ID != "11" || result[1].ID != "12" || result[2].ID != "10" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+	want = []bunnyRecord{
+		{ID: 10, Value: "192.0.2.8", Weight: 10, MonitorType: 1},
+		{ID: 11, Value: "192.0.2.2", Weight: 20},
+		{ID: 12, Value: "192.0.2.9", Weight: 30, MonitorType: 2},
+	}
+	for i, w := range want {
+		got := zone.Records[i]
+		if got.ID != w.ID || got.Value != w.Value || got.Weight != w.Weight || got.MonitorType != w.MonitorType {
+			t.Fatalf("zone.Records[%d] != %+v => %+v", i, w, got)
+		}
+	}
+}
+
+func Test_SetRecordsRRset(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "192.0.2.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "www", Value: "192.0.2.9", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "www", Value: "other", TTL: 120},
+		},
+	}
+	m := newMockAPI(t, zone)
+
+	values := func() []string {
+		var values []string
+		for _, record := range zone.Records {
+			if record.Type == bunnyTypeA {
+				values = append(values, fmt.Sprintf("%d=%s", record.ID, record.Value))
+			}
+		}
+		return values
+	}
+
+	p := &Provider{AccessKey: "key"}
+	result, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1", TTL: ttl},
+		{Type: "A", Name: "www", Value: "192.0.2.2", TTL: ttl},
+		{Type: "A", Name: "www", Value: "192.0.2.3", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 3 || result[0].ID != "10" || result[1].ID != "11" || result[2].ID == "" {
+		t.Fatalf("unexpected result => %+v", result)
+	}
+	expected := []string{"10=192.0.2.1", "11=192.0.2.2", result[2].ID + "=192.0.2.3"}
+	if strings.Join(values(), ",") != strings.Join(expected, ",") {
+		t.Fatalf("values() != expected => %v != %v", values(), expected)
+	}
+	if m.requestCount("POST") != 1 || m.requestCount("PUT") != 1 || m.requestCount("DELETE") != 0 {
+		t.Fatalf("unexpected requests => %v", m.requests)
+	}
+
+	// the records left out of the RRset are deleted, other types are kept
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.2"},
+		{Type: "A", Name: "WWW.example.com.", Value: "192.0.2.1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"10=192.0.2.1", "11=192.0.2.2"}
+	if strings.Join(values(), ",") != strings.Join(expected, ",") {
+		t.Fatalf("values() != expected => %v != %v", values(), expected)
+	}
+	if len(zone.Records) != 3 || zone.Records[2].Type != bunnyTypeTXT {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+}
+
+func Test_SetRecordsAmbiguous(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "a", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "b", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+
+	// the record with the same data is updated
+	result, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "test", Value: "b", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result[0].ID != "11" {
+		t.Fatalf(`result[0].ID != "11" => %s`, result[0].ID)
+	}
+
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "test", Value: "c", TTL: ttl},
+	}); err == nil {
+		t.Fatal("expected an error for more than 1 matching record")
+	}
+}
+
+func Test_MultiMatchStrategy(t *testing.T) {
+	testCases := []struct {
+		strategy MultiMatchStrategy
+		updated  int
+		expected []string
+	}{
+		{MultiMatchError, 0, nil},
+		{MultiMatchUpdateFirst, 1, []string{"c", "b"}},
+		{MultiMatchUpdateAll, 2, []string{"c", "c"}},
+	}
+
+	for _, c := range testCases {
+		zone := &mockZone{
+			bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+			Records: []bunnyRecord{
+				{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "a", TTL: 120},
+				{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "b", TTL: 120},
+			},
+		}
+		newMockAPI(t, zone)
+
+		p := &Provider{AccessKey: "key", MultiMatchStrategy: c.strategy}
+		result, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+			{Type: "TXT", Name: "test", Value: "c", TTL: ttl},
+		})
+		if c.expected == nil {
+			if err == nil {
+				t.Fatalf("expected an error for strategy %d", c.strategy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(result) != c.updated {
+			t.Fatalf("unexpected result for strategy %d => %+v", c.strategy, result)
+		}
+		for i, value := range c.expected {
+			if zone.Records[i].Value != value {
+				t.Fatalf("zone.Records[%d].Value != %q for strategy %d => %q", i, value, c.strategy, zone.Records[i].Value)
+			}
+		}
+	}
+}
+
+func Test_CreateRecordIfAbsent(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	record := libdns.Record{Type: "TXT", Name: "_acme-challenge", Value: "token", TTL: ttl}
+
+	first, created, err := p.CreateRecordIfAbsent(context.TODO(), "example.com.", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || first.ID == "" {
+		t.Fatalf("expected the record to be created => %+v", first)
+	}
+
+	second, created, err := p.CreateRecordIfAbsent(context.TODO(), "example.com.", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || second.ID != first.ID {
+		t.Fatalf("expected the existing record %s => %+v", first.ID, second)
+	}
+	if len(zone.Records) != 1 {
+		t.Fatalf("len(zone.Records) != 1 => %d", len(zone.Records))
+	}
+}
+
+func Test_RecordExists(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token1", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token2", TTL: 120},
+			{ID: 12, Type: bunnyTypeMX, Name: "", Value: "mail.example.com", Priority: 10, TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	tests := []struct {
+		record libdns.Record
+		exists bool
+	}{
+		{libdns.Record{Type: "TXT", Name: "_acme-challenge", Value: "token2"}, true},
+		{libdns.Record{Type: "TXT", Name: "_acme-challenge.example.com.", Value: "token1", TTL: 2 * ttl}, true},
+		{libdns.Record{Type: "TXT", Name: "_acme-challenge", Value: "token3"}, false},
+		{libdns.Record{Type: "TXT", Name: "_acme-challenge", Value: "TOKEN1"}, false},
+		{libdns.Record{Type: "TXT", Name: "other", Value: "token1"}, false},
+		{libdns.Record{Type: "MX", Name: "@", Value: "Mail.example.com.", Priority: 10}, true},
+		{libdns.Record{Type: "MX", Name: "@", Value: "mail.example.com", Priority: 20}, false},
+	}
+	for _, test := range tests {
+		exists, err := p.RecordExists(context.TODO(), "example.com.", test.record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != test.exists {
+			t.Fatalf("RecordExists(%+v) != %t", test.record, test.exists)
+		}
+	}
+}
+
+func Test_CreateRecordIfAbsentConcurrentCreate(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	m := &mockAPI{zones: []*mockZone{zone}, nextID: 1000}
+
+	// Another client creates the record right before ours, and the API
+	// rejects ours as a duplicate.
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			m.mu.Lock()
+			zone.Records = append(zone.Records, bunnyRecord{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token", TTL: 120})
+			m.mu.Unlock()
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.ServeHTTP(w, r)
+	}))
+
+	p := &Provider{AccessKey: "key"}
+	record := libdns.Record{Type: "TXT", Name: "_acme-challenge", Value: "token", TTL: ttl}
+
+	result, created, err := p.CreateRecordIfAbsent(context.TODO(), "example.com", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || result.ID != "10" {
+		t.Fatalf("expected the concurrently created record => %+v", result)
+	}
+
+	// A failure without a concurrent creation is returned
+	zone.Records = nil
+	record.Value = "other"
+	if _, _, err := p.CreateRecordIfAbsent(context.TODO(), "example.com", record); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func Test_MixedCaseRoundTrip(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key", IdempotentAppend: true}
+	records := []libdns.Record{
+		{Type: "TXT", Name: "Test", Value: "MixedCase Token", TTL: ttl},
+		{Type: "CNAME", Name: "www", Value: "Origin.Example.NET", TTL: ttl},
+	}
+	if _, err := p.AppendRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, record := range result {
+		if record.Value != records[k].Value || record.Name != records[k].Name {
+			t.Fatalf("result[%d] != records[%d] => %+v != %+v", k, k, record, records[k])
+		}
+	}
+
+	// Should the API normalize the case of a hostname, the record is still
+	// the same; the case of a TXT value is significant though.
+	zone.Records[1].Value = "origin.example.net"
+	records[0].Value = "mixedcase token"
+	if _, err := p.AppendRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 3 {
+		t.Fatalf("len(zone.Records) != 3 => %d", len(zone.Records))
+	}
+	if zone.Records[2].Value != "mixedcase token" {
+		t.Fatalf(`zone.Records[2].Value != "mixedcase token" => %s`, zone.Records[2].Value)
+	}
+}
+
+func Test_RecordKey(t *testing.T) {
+	testCases := []struct {
+		a, b libdns.Record
+		same bool
+	}{
+		{libdns.Record{Type: "A", Name: "www", Value: "127.0.0.1"}, libdns.Record{ID: "1", Type: "a", Name: "WWW.", Value: "127.0.0.1", TTL: ttl}, true},
+		{libdns.Record{Type: "A", Name: "www", Value: "127.0.0.1"}, libdns.Record{Type: "A", Name: "www", Value: "127.0.0.2"}, false},
+		{libdns.Record{Type: "AAAA", Name: "", Value: "::1"}, libdns.Record{Type: "AAAA", Name: "@", Value: "::1"}, true},
+		{libdns.Record{Type: "A", Name: "www", Value: "127.0.0.1"}, libdns.Record{Type: "AAAA", Name: "www", Value: "127.0.0.1"}, false},
+		{libdns.Record{Type: "CNAME", Name: "www", Value: "Origin.Example.net."}, libdns.Record{Type: "CNAME", Name: "www", Value: "origin.example.net"}, true},
+		{libdns.Record{Type: "TXT", Name: "test", Value: "Token"}, libdns.Record{Type: "TXT", Name: "test", Value: "token"}, false},
+		{libdns.Record{Type: "TXT", Name: "test", Value: "a b"}, libdns.Record{Type: "TXT", Name: "test a", Value: "b"}, false},
+		{libdns.Record{Type: "MX", Name: "", Value: "mail.example.com", Priority: 10}, libdns.Record{Type: "MX", Name: "", Value: "MAIL.example.com", Priority: 10}, true},
+		{libdns.Record{Type: "MX", Name: "", Value: "mail.example.com", Priority: 10}, libdns.Record{Type: "MX", Name: "", Value: "mail.example.com", Priority: 20}, false},
+		{libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 5}, libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 5}, true},
+		{libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 5}, libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 6}, false},
+		{libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 5}, libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5061 sip.example.com", Priority: 10, Weight: 5}, false},
+		{libdns.Record{Type: "CAA", Name: "", Value: `0 issue "letsencrypt.org"`}, libdns.Record{Type: "CAA", Name: "", Value: `0 issue "letsencrypt.org"`}, true},
+		{libdns.Record{Type: "CAA", Name: "", Value: `0 issue "letsencrypt.org"`}, libdns.Record{Type: "CAA", Name: "", Value: `128 issue "letsencrypt.org"`}, false},
+		{libdns.Record{Type: "NS", Name: "sub", Value: "ns1.example.net"}, libdns.Record{Type: "NS", Name: "sub", Value: "NS1.example.net."}, true},
+		{libdns.Record{Type: "PTR", Name: "1", Value: "host.example.com"}, libdns.Record{Type: "PTR", Name: "1", Value: "other.example.com"}, false},
+		{libdns.Record{Type: "Redirect", Name: "go", Value: "https://example.com/Path"}, libdns.Record{Type: "Redirect", Name: "go", Value: "https://example.com/path"}, false},
+		{libdns.Record{Type: "Flatten", Name: "", Value: "origin.example.net"}, libdns.Record{Type: "Flatten", Name: "", Value: "origin.example.net"}, true},
+	}
+
+	for k, c := range testCases {
+		if same := RecordKey(c.a) == RecordKey(c.b); same != c.same {
+			t.Fatalf("testCases[%d]: RecordKey(a) == RecordKey(b) != %t => %q, %q", k, c.same, RecordKey(c.a), RecordKey(c.b))
+		}
+		if same := sameRecord("example.com", c.a, c.b); same != c.same {
+			t.Fatalf("testCases[%d]: sameRecord(a, b) != %t", k, c.same)
+		}
+	}
+}
+
+func Test_ReplaceRecord(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120, Accelerated: true, AcceleratedPullZoneID: 5},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "a", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "test", Value: "b", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	replaced, err := p.ReplaceRecord(context.TODO(), "example.com",
+		libdns.Record{Type: "A", Name: "www", Value: "127.0.0.1"},
+		libdns.Record{Type: "A", Name: "www", Value: "127.0.0.2", TTL: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.ID != "10" {
+		t.Fatalf(`replaced.ID != "10" => %s`, replaced.ID)
+	}
+	if stored := zone.Records[0]; stored.ID != 10 || stored.Value != "127.0.0.2" || !stored.Accelerated || stored.AcceleratedPullZoneID != 5 {
+		t.Fatalf("unexpected stored record => %+v", stored)
+	}
+
+	// The value tells records with the same name and type apart
+	if _, err := p.ReplaceRecord(context.TODO(), "example.com",
+		libdns.Record{Type: "TXT", Name: "test", Value: "b"},
+		libdns.Record{Type: "TXT", Name: "test", Value: "c", TTL: ttl}); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[1].Value != "a" || zone.Records[2].Value != "c" {
+		t.Fatalf("unexpected stored records => %+v", zone.Records)
+	}
+
+	if _, err := p.ReplaceRecord(context.TODO(), "example.com",
+		libdns.Record{Type: "TXT", Name: "test"},
+		libdns.Record{Type: "TXT", Name: "test", Value: "d", TTL: ttl}); err == nil {
+		t.Fatal("expected an error for more than 1 matching record")
+	}
+
+	_, err = p.ReplaceRecord(context.TODO(), "example.com",
+		libdns.Record{Type: "TXT", Name: "missing", Value: "a"},
+		libdns.Record{Type: "TXT", Name: "missing", Value: "b", TTL: ttl})
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+}
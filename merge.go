@@ -0,0 +1,163 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// ErrMergeConflict is returned by MergeRecords with MergeError if a record
+// conflicts with an existing record.
+var ErrMergeConflict = errors.New("record conflicts with an existing record")
+
+// MergeStrategy controls how MergeRecords handles a record that conflicts
+// with an existing record, i.e. one with the same name and type but other
+// data.
+type MergeStrategy int
+
+const (
+	// MergeKeepExisting keeps the existing record and skips the new one.
+	MergeKeepExisting MergeStrategy = iota
+	// MergeOverwrite updates the existing record with the new one.
+	MergeOverwrite
+	// MergeError fails the merge without changing the zone.
+	MergeError
+)
+
+// MergeSummary lists the actions taken by MergeRecords.
+type MergeSummary struct {
+	// Created are the records that did not exist yet.
+	Created []libdns.Record
+	// Updated are the records that overwrote a conflicting record.
+	Updated []libdns.Record
+	// Unchanged are the existing records that were identical to a new one.
+	Unchanged []libdns.Record
+	// Skipped are the new records that conflicted with an existing record
+	// that was kept.
+	Skipped []libdns.Record
+}
+
+// MergeRecords merges records into the zone: records that don't exist yet
+// are created, and records identical to an existing one (see RecordKey) are
+// left alone. A record that has the same name and type as existing records
+// but none of their data conflicts with them, which strategy resolves.
+//
+// With MergeOverwrite, each conflicting record overwrites a distinct existing
+// record of its RRset, like with SetRecords, and the conflicting records
+// beyond the existing ones are created. Existing records that are not
+// overwritten are kept.
+func (p *Provider) MergeRecords(ctx context.Context, zone string, records []libdns.Record, strategy MergeStrategy) (MergeSummary, error) {
+	switch strategy {
+	case MergeKeepExisting, MergeOverwrite, MergeError:
+	default:
+		return MergeSummary{}, fmt.Errorf("invalid merge strategy: %d", strategy)
+	}
+
+	if err := validateCNAMEConflicts(unFQDN(zone), records); err != nil {
+		return MergeSummary{}, err
+	}
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return MergeSummary{}, err
+	}
+
+	existingRecords, err := p.getZoneRecords(ctx, resolved)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return MergeSummary{}, err
+	}
+
+	summary := MergeSummary{}
+	var toCreate, toUpdate, toOverwrite []libdns.Record
+	identicalIDs := map[string]bool{}
+	for _, record := range records {
+		identical, conflicting := classifyRecord(resolved.domain(), record, existingRecords)
+		switch {
+		case identical != nil:
+			identicalIDs[identical.ID] = true
+			identical.Name = p.outputName(unFQDN(zone), identical.Name)
+			summary.Unchanged = append(summary.Unchanged, *identical)
+		case len(conflicting) == 0:
+			toCreate = append(toCreate, record)
+		case strategy == MergeKeepExisting:
+			summary.Skipped = append(summary.Skipped, record)
+		case strategy == MergeError:
+			return MergeSummary{}, fmt.Errorf("%w: %s record %s in zone %s", ErrMergeConflict, record.Type, record.Name, resolved.Domain)
+		default:
+			toOverwrite = append(toOverwrite, record)
+		}
+	}
+
+	// The conflicting records are paired with distinct existing records of
+	// their RRset, other than the ones reported as unchanged, like with
+	// SetRecords; the records beyond them are created.
+	var candidates []Record
+	for _, record := range existingRecords {
+		if !identicalIDs[record.ID] {
+			candidates = append(candidates, record)
+		}
+	}
+	for i, pair := range pairRecords(resolved.domain(), toOverwrite, candidates) {
+		record := toOverwrite[i]
+		if pair < 0 {
+			toCreate = append(toCreate, record)
+			continue
+		}
+		record.ID = candidates[pair].ID
+		toUpdate = append(toUpdate, record)
+	}
+
+	created := make([][]libdns.Record, len(toCreate))
+	createErr := p.forEachBatch(ctx, len(toCreate), p.Concurrency, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		record, err := p.createRecord(ctx, resolved, toCreate[i])
+		if err != nil {
+			p.logError(OperationCreateRecord, resolved.Domain, err, toCreate[i])
+			return err
+		}
+		created[i] = []libdns.Record{record.Record}
+		return nil
+	})
+	summary.Created = p.outputRecords(unFQDN(zone), flatten(created))
+	if createErr != nil && !p.ContinueOnError {
+		return summary, createErr
+	}
+
+	updated := make([][]libdns.Record, len(toUpdate))
+	updateErr := p.forEachBatch(ctx, len(toUpdate), 1, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		if err := p.updateRecord(ctx, resolved, toUpdate[i], storedRecord(existingRecords, toUpdate[i].ID)); err != nil {
+			p.logError(OperationUpdateRecord, resolved.Domain, err, toUpdate[i])
+			return err
+		}
+		updated[i] = []libdns.Record{toUpdate[i]}
+		return nil
+	})
+	summary.Updated = p.outputRecords(unFQDN(zone), flatten(updated))
+
+	return summary, errors.Join(createErr, updateErr)
+}
+
+// classifyRecord finds the existing record identical to record, or else the
+// existing records with the same name and type, which conflict with it.
+func classifyRecord(zone string, record libdns.Record, existing []Record) (*libdns.Record, []libdns.Record) {
+	var conflicting []libdns.Record
+	for _, candidate := range existing {
+		if sameRecord(zone, candidate.Record, record) {
+			return &candidate.Record, nil
+		}
+		if strings.EqualFold(candidate.Type, record.Type) && normalizeName(candidate.Name, zone) == normalizeName(record.Name, zone) {
+			conflicting = append(conflicting, candidate.Record)
+		}
+	}
+	return nil, conflicting
+}
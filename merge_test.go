@@ -0,0 +1,178 @@
+package bunny
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func newMergeTestZone() *mockZone {
+	return &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test", Value: "old", TTL: 120},
+		},
+	}
+}
+
+var mergeTestRecords = []libdns.Record{
+	{Type: "A", Name: "www", Value: "127.0.0.1", TTL: ttl},
+	{Type: "TXT", Name: "test", Value: "new", TTL: ttl},
+	{Type: "TXT", Name: "other", Value: "other", TTL: ttl},
+}
+
+func Test_MergeRecordsKeepExisting(t *testing.T) {
+	zone := newMergeTestZone()
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	summary, err := p.MergeRecords(context.TODO(), "example.com", mergeTestRecords, MergeKeepExisting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summary.Unchanged) != 1 || summary.Unchanged[0].ID != "10" {
+		t.Fatalf("unexpected unchanged records => %+v", summary.Unchanged)
+	}
+	if len(summary.Skipped) != 1 || summary.Skipped[0].Value != "new" {
+		t.Fatalf("unexpected skipped records => %+v", summary.Skipped)
+	}
+	if len(summary.Created) != 1 || summary.Created[0].Name != "other" || len(summary.Updated) != 0 {
+		t.Fatalf("unexpected summary => %+v", summary)
+	}
+	if len(zone.Records) != 3 || zone.Records[1].Value != "old" {
+		t.Fatalf("unexpected records => %+v", zone.Records)
+	}
+}
+
+func Test_MergeRecordsOverwrite(t *testing.T) {
+	zone := newMergeTestZone()
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	summary, err := p.MergeRecords(context.TODO(), "example.com", mergeTestRecords, MergeOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summary.Updated) != 1 || summary.Updated[0].ID != "11" {
+		t.Fatalf("unexpected updated records => %+v", summary.Updated)
+	}
+	if len(summary.Created) != 1 || len(summary.Unchanged) != 1 || len(summary.Skipped) != 0 {
+		t.Fatalf("unexpected summary => %+v", summary)
+	}
+	if len(zone.Records) != 3 || zone.Records[1].Value != "new" {
+		t.Fatalf("unexpected records => %+v", zone.Records)
+	}
+}
+
+func Test_MergeRecordsOverwriteRRset(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "api", Value: "127.0.0.1", TTL: 120},
+			{ID: 12, Type: bunnyTypeA, Name: "api", Value: "127.0.0.2", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	summary, err := p.MergeRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "1.1.1.1", TTL: ttl},
+		{Type: "A", Name: "www", Value: "2.2.2.2", TTL: ttl},
+		{Type: "A", Name: "api", Value: "127.0.0.2", TTL: ttl},
+		{Type: "A", Name: "api", Value: "127.0.0.3", TTL: ttl},
+	}, MergeOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// www: the first record overwrites 10, the second one is created; api:
+	// 12 is unchanged, so the new record overwrites 11
+	if len(summary.Updated) != 2 || summary.Updated[0].ID != "10" || summary.Updated[0].Value != "1.1.1.1" || summary.Updated[1].ID != "11" || summary.Updated[1].Value != "127.0.0.3" {
+		t.Fatalf("unexpected updated records => %+v", summary.Updated)
+	}
+	if len(summary.Created) != 1 || summary.Created[0].Value != "2.2.2.2" {
+		t.Fatalf("unexpected created records => %+v", summary.Created)
+	}
+	if len(summary.Unchanged) != 1 || summary.Unchanged[0].ID != "12" {
+		t.Fatalf("unexpected unchanged records => %+v", summary.Unchanged)
+	}
+
+	values := map[string]bool{}
+	for _, record := range zone.Records {
+		values[record.Name+" "+record.Value] = true
+	}
+	for _, want := range []string{"www 1.1.1.1", "www 2.2.2.2", "api 127.0.0.2", "api 127.0.0.3"} {
+		if !values[want] {
+			t.Fatalf("missing record %s => %+v", want, zone.Records)
+		}
+	}
+	if len(zone.Records) != 4 {
+		t.Fatalf("len(zone.Records) != 4 => %+v", zone.Records)
+	}
+}
+
+func Test_MergeRecordsError(t *testing.T) {
+	zone := newMergeTestZone()
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	_, err := p.MergeRecords(context.TODO(), "example.com", mergeTestRecords, MergeError)
+	if !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("expected ErrMergeConflict, got %v", err)
+	}
+	if len(zone.Records) != 2 || m.requestCount("PUT") != 0 || m.requestCount("POST") != 0 {
+		t.Fatalf("the zone was changed => %+v", zone.Records)
+	}
+
+	// Without conflicts, records are merged as with any strategy
+	summary, err := p.MergeRecords(context.TODO(), "example.com", mergeTestRecords[2:], MergeError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Created) != 1 {
+		t.Fatalf("unexpected summary => %+v", summary)
+	}
+
+	if _, err := p.MergeRecords(context.TODO(), "example.com", nil, MergeStrategy(7)); err == nil {
+		t.Fatal("expected an error for an invalid strategy")
+	}
+}
+
+func Test_MergeRecordsTimeout(t *testing.T) {
+	zone := newMergeTestZone()
+	m := &mockAPI{zones: []*mockZone{zone}, nextID: 1000}
+
+	// Creating the "slow" record hangs until the request is canceled
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPut && strings.Contains(string(body), `"slow"`) {
+			<-r.Context().Done()
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		m.ServeHTTP(w, r)
+	}))
+
+	p := &Provider{AccessKey: "key", RecordTimeout: 100 * time.Millisecond, ContinueOnError: true}
+	summary, err := p.MergeRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "slow", Value: "slow", TTL: ttl},
+		{Type: "TXT", Name: "fast", Value: "fast", TTL: ttl},
+	}, MergeKeepExisting)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if len(summary.Created) != 1 || summary.Created[0].Name != "fast" {
+		t.Fatalf("unexpected created records => %+v", summary.Created)
+	}
+}
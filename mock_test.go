@@ -0,0 +1,235 @@
+package bunny
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var ttl = time.Duration(120 * time.Second)
+
+// mockZone is a DNS zone as served by the mock API.
+type mockZone struct {
+	bunnyZone
+	Records []bunnyRecord `json:"Records"`
+
+	// fail makes requests for the zone fail with an internal server error.
+	fail bool
+	// unlisted hides the zone from the zone search, like a zone supplied by
+	// OnZoneNotFound.
+	unlisted bool
+}
+
+// mockAPI is an in-memory stand-in for the Bunny.net DNS API.
+type mockAPI struct {
+	mu       sync.Mutex
+	zones    []*mockZone
+	nextID   int
+	requests []string
+}
+
+// newMockAPI starts a mock API serving the given zones and points the
+// provider at it for the duration of the test.
+func newMockAPI(t *testing.T, zones ...*mockZone) *mockAPI {
+	m := &mockAPI{zones: zones, nextID: 1000}
+	serveTestAPI(t, m)
+	return m
+}
+
+// serveTestAPI points the provider at a test server using handler for the
+// duration of the test.
+func serveTestAPI(t *testing.T, handler http.Handler) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	previous := apiBaseURL
+	apiBaseURL = server.URL
+	t.Cleanup(func() { apiBaseURL = previous })
+}
+
+// requestCount returns the number of requests made with the given method.
+func (m *mockAPI) requestCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, request := range m.requests {
+		if strings.HasPrefix(request, method+" ") {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *mockAPI) findZone(id string) *mockZone {
+	for _, zone := range m.zones {
+		if strconv.Itoa(zone.ID) == id {
+			return zone
+		}
+	}
+	return nil
+}
+
+func (m *mockAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("AccessKey") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "dnszone" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		search := strings.ToLower(r.URL.Query().Get("search"))
+		items := []*mockZone{}
+		for _, zone := range m.zones {
+			if !zone.unlisted && strings.Contains(strings.ToLower(zone.Domain), search) {
+				items = append(items, zone)
+			}
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("perPage"))
+		if page < 1 || perPage < 5 || perPage > 1000 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		start := (page - 1) * perPage
+		if start > len(items) {
+			start = len(items)
+		}
+		end := start + perPage
+		if end > len(items) {
+			end = len(items)
+		}
+		writeJSON(w, map[string]any{
+			"Items":        items[start:end],
+			"CurrentPage":  page,
+			"TotalItems":   len(items),
+			"HasMoreItems": end < len(items),
+		})
+
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		zone := m.findZone(parts[1])
+		if zone == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if zone.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, zone)
+
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		zone := m.findZone(parts[1])
+		if zone == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		id := zone.ID
+		if err := json.NewDecoder(r.Body).Decode(&zone.bunnyZone); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		zone.ID = id
+		writeJSON(w, zone)
+
+	case len(parts) == 3 && parts[2] == "dnssec":
+		zone := m.findZone(parts[1])
+		if zone == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			zone.DnsSecEnabled = true
+			writeJSON(w, dnssecResponse{
+				Enabled:    true,
+				DsRecord:   zone.Domain + ". 3600 IN DS 12345 13 2 ABCDEF",
+				Digest:     "ABCDEF",
+				DigestType: 2,
+				Algorithm:  13,
+				PublicKey:  "cHVibGlj",
+				KeyTag:     12345,
+				Flags:      257,
+			})
+		case http.MethodDelete:
+			zone.DnsSecEnabled = false
+			writeJSON(w, dnssecResponse{})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+	case len(parts) == 3 && parts[2] == "records" && r.Method == http.MethodPut:
+		zone := m.findZone(parts[1])
+		if zone == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		record := bunnyRecord{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.nextID++
+		record.ID = m.nextID
+		zone.Records = append(zone.Records, record)
+		writeJSON(w, record)
+
+	case len(parts) == 4 && parts[2] == "records":
+		zone := m.findZone(parts[1])
+		if zone == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for i, record := range zone.Records {
+			if strconv.Itoa(record.ID) != parts[3] {
+				continue
+			}
+			switch r.Method {
+			case http.MethodPost:
+				update := bunnyRecord{}
+				if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				update.ID = record.ID
+				zone.Records[i] = update
+				w.WriteHeader(http.StatusNoContent)
+			case http.MethodDelete:
+				zone.Records = append(zone.Records[:i], zone.Records[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic(fmt.Sprintf("encoding mock response: %v", err))
+	}
+}
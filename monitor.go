@@ -0,0 +1,79 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+)
+
+// MonitorType is the type of health check monitoring a record. A record
+// whose monitor fails is disabled until it is healthy again.
+type MonitorType int
+
+const (
+	// MonitorNone does not monitor the record.
+	MonitorNone MonitorType = 0
+	// MonitorPing monitors the record by pinging its value.
+	MonitorPing MonitorType = 1
+	// MonitorHTTP monitors the record with HTTP requests to its value.
+	MonitorHTTP MonitorType = 2
+)
+
+// MonitorStatus is the result of the health check monitoring a record.
+type MonitorStatus int
+
+const (
+	// MonitorStatusUnknown is the status of a record that is not monitored,
+	// or not checked yet.
+	MonitorStatusUnknown MonitorStatus = 0
+	// MonitorStatusOnline is the status of a record that passed its check.
+	MonitorStatusOnline MonitorStatus = 1
+	// MonitorStatusOffline is the status of a record that failed its check,
+	// which is disabled until it is healthy again.
+	MonitorStatusOffline MonitorStatus = 2
+)
+
+// SetRecordMonitor sets the health check monitoring the record with the
+// given ID in the zone. MonitorNone removes the monitor. All other fields of
+// the record are kept. ErrRecordNotFound is returned if the zone has no
+// record with the ID.
+func (p *Provider) SetRecordMonitor(ctx context.Context, zone, id string, monitor MonitorType) error {
+	if p.ReadOnly {
+		return ErrReadOnly
+	}
+	if monitor < MonitorNone || monitor > MonitorHTTP {
+		return fmt.Errorf("invalid monitor type: %d", monitor)
+	}
+
+	p.log(OperationUpdateRecord, unFQDN(zone), fmt.Sprintf("setting monitor of record %s in zone %s", id, unFQDN(zone)))
+
+	err := p.setRecordMonitor(ctx, unFQDN(zone), id, monitor)
+	if err != nil {
+		p.logError(OperationUpdateRecord, unFQDN(zone), err)
+		return err
+	}
+
+	p.log(OperationUpdateRecord, unFQDN(zone), fmt.Sprintf("done setting monitor of record %s in zone %s", id, unFQDN(zone)))
+
+	return nil
+}
+
+func (p *Provider) setRecordMonitor(ctx context.Context, zone, id string, monitor MonitorType) error {
+	resolved, err := p.resolveZoneForWrite(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	record, found, err := p.getZoneRecord(ctx, resolved.ID, id)
+	if err != nil {
+		return err
+	}
+	if !found || (resolved.nameBase != "" && !isWithin(record.Name, resolved.nameBase)) {
+		return fmt.Errorf("%w: %s in zone %s", ErrRecordNotFound, id, zone)
+	}
+
+	record = writableBunnyRecord(record)
+	record.MonitorType = int(monitor)
+
+	_, err = p.postRecord(ctx, resolved.ID, id, record)
+	return err
+}
@@ -0,0 +1,74 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_MonitorRoundTrip(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120, MonitorType: int(MonitorHTTP), MonitorStatus: int(MonitorStatusOnline)},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetBunnyRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].MonitorType != MonitorHTTP || records[0].MonitorStatus != MonitorStatusOnline {
+		t.Fatalf("unexpected record => %+v", records[0])
+	}
+
+	record := records[0].Record
+	record.Value = "127.0.0.2"
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+	if stored := zone.Records[0]; stored.Value != "127.0.0.2" || stored.MonitorType != int(MonitorHTTP) {
+		t.Fatalf("unexpected stored record => %+v", stored)
+	}
+}
+
+func Test_SetRecordMonitor(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120, Accelerated: true},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	if err := p.SetRecordMonitor(context.TODO(), "example.com.", "10", MonitorPing); err != nil {
+		t.Fatal(err)
+	}
+	if stored := zone.Records[0]; stored.MonitorType != int(MonitorPing) || stored.Value != "127.0.0.1" || !stored.Accelerated {
+		t.Fatalf("unexpected stored record => %+v", stored)
+	}
+
+	if err := p.SetRecordMonitor(context.TODO(), "example.com.", "11", MonitorPing); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+	if err := p.SetRecordMonitor(context.TODO(), "example.com.", "10", 5); err == nil {
+		t.Fatal("expected an error for an invalid monitor type")
+	}
+
+	// A name within the zone resolves to the zone, and only its records can
+	// be monitored
+	if err := p.SetRecordMonitor(context.TODO(), "www.example.com.", "10", MonitorHTTP); err != nil {
+		t.Fatal(err)
+	}
+	if stored := zone.Records[0]; stored.MonitorType != int(MonitorHTTP) {
+		t.Fatalf("unexpected stored record => %+v", stored)
+	}
+	if err := p.SetRecordMonitor(context.TODO(), "api.example.com.", "10", MonitorNone); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// DefaultResolvers are the public resolvers queried by WaitForPropagation
+// when none are given.
+var DefaultResolvers = []string{"1.1.1.1:53", "8.8.8.8:53"}
+
+// propagationInterval is the time between two rounds of queries.
+var propagationInterval = 2 * time.Second
+
+// WaitForPropagation queries the given resolvers until all of them return
+// the record, or until ctx is done. The record name is relative to zone.
+// Resolvers are given as "host" or "host:port"; if none are given,
+// DefaultResolvers are used. A, AAAA, CNAME, MX, NS and TXT records are
+// supported.
+func (p *Provider) WaitForPropagation(ctx context.Context, zone string, record libdns.Record, resolvers []string) error {
+	if len(resolvers) == 0 {
+		resolvers = DefaultResolvers
+	}
+
+	fqdn := libdns.AbsoluteName(relativeName(record.Name, zone), unFQDN(zone)+".")
+
+	for {
+		visible := true
+		for _, resolver := range resolvers {
+			found, err := lookupRecord(ctx, resolver, fqdn, record)
+			if err != nil {
+				return err
+			}
+			if !found {
+				visible = false
+				break
+			}
+		}
+
+		if visible {
+			p.log(OperationGetRecords, unFQDN(zone), fmt.Sprintf("%s record %s is visible at %d resolver(s)", record.Type, fqdn, len(resolvers)), record)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for propagation of %s record %s: %w", record.Type, fqdn, ctx.Err())
+		case <-time.After(propagationInterval):
+		}
+	}
+}
+
+// lookupRecord reports whether resolver returns record at fqdn. Lookup
+// failures other than an unsupported record type count as not found, since
+// the record may simply not have propagated yet.
+func lookupRecord(ctx context.Context, resolver, fqdn string, record libdns.Record) (bool, error) {
+	r := newResolver(resolver)
+
+	sameHost := func(a, b string) bool {
+		return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+	}
+
+	switch record.Type {
+	case "A", "AAAA":
+		network := "ip4"
+		if record.Type == "AAAA" {
+			network = "ip6"
+		}
+		want := net.ParseIP(record.Value)
+		ips, err := r.LookupIP(ctx, network, fqdn)
+		if err != nil {
+			return false, nil
+		}
+		for _, ip := range ips {
+			if ip.Equal(want) {
+				return true, nil
+			}
+		}
+	case "CNAME":
+		target, err := r.LookupCNAME(ctx, fqdn)
+		if err != nil {
+			return false, nil
+		}
+		return sameHost(target, record.Value), nil
+	case "MX":
+		mxs, err := r.LookupMX(ctx, fqdn)
+		if err != nil {
+			return false, nil
+		}
+		for _, mx := range mxs {
+			if sameHost(mx.Host, record.Value) {
+				return true, nil
+			}
+		}
+	case "NS":
+		nss, err := r.LookupNS(ctx, fqdn)
+		if err != nil {
+			return false, nil
+		}
+		for _, ns := range nss {
+			if sameHost(ns.Host, record.Value) {
+				return true, nil
+			}
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, fqdn)
+		if err != nil {
+			return false, nil
+		}
+		for _, txt := range txts {
+			if txt == record.Value {
+				return true, nil
+			}
+		}
+	default:
+		return false, fmt.Errorf("waiting for propagation of %s records is not supported", record.Type)
+	}
+
+	return false, nil
+}
+
+// newResolver returns a resolver that queries the given resolver, given as
+// "host" or "host:port".
+func newResolver(resolver string) *net.Resolver {
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, resolver)
+		},
+	}
+}
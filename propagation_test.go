@@ -0,0 +1,92 @@
+package bunny
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveTestDNS answers TXT queries on a local UDP port with the value
+// returned by answer, which may be empty to answer without records.
+func serveTestDNS(t *testing.T, answer func() string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if value := answer(); value != "" && query.Questions[0].Type == dnsmessage.TypeTXT {
+				response.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{
+						Name:  query.Questions[0].Name,
+						Type:  dnsmessage.TypeTXT,
+						Class: dnsmessage.ClassINET,
+						TTL:   60,
+					},
+					Body: &dnsmessage.TXTResource{TXT: []string{value}},
+				}}
+			}
+
+			packed, err := response.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func Test_WaitForPropagation(t *testing.T) {
+	previous := propagationInterval
+	propagationInterval = 10 * time.Millisecond
+	t.Cleanup(func() { propagationInterval = previous })
+
+	var queries atomic.Int32
+	resolver := serveTestDNS(t, func() string {
+		// the record becomes visible with the third query
+		if queries.Add(1) < 3 {
+			return ""
+		}
+		return "token"
+	})
+
+	p := &Provider{}
+	record := libdns.Record{Type: "TXT", Name: "_acme-challenge", Value: "token"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.WaitForPropagation(ctx, "example.com.", record, []string{resolver}); err != nil {
+		t.Fatal(err)
+	}
+
+	record.Value = "other"
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := p.WaitForPropagation(ctx, "example.com.", record, []string{resolver}); err == nil {
+		t.Fatal("expected an error when the record never becomes visible")
+	}
+}
@@ -2,70 +2,968 @@ package bunny
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/libdns/libdns"
 )
 
 // Provider facilitates DNS record manipulation with Bunny.net
+//
+// All methods of a Provider are safe for concurrent use. Its configuration
+// fields must not be changed while methods are running, and a Provider must
+// not be copied after first use. The Logger, EventLogger and Trace hooks may
+// be called concurrently.
+//
+// Concurrent SetRecords and SyncManagedRecords calls for the same names of a
+// zone are serialized by the Provider: each call reads the stored records
+// once the calls before it are done, in no particular order. Bunny.net
+// applies each record change on its own, so other concurrent calls changing
+// the same records are each applied in full, in no particular order.
 type Provider struct {
 	// AccessKey is the Bunny.net API key - see https://docs.bunny.net/reference/bunnynet-api-overview
 	AccessKey string                        `json:"access_key"`
 	Debug     bool                          `json:"debug"`
 	Logger    func(string, []libdns.Record) `json:"-"`
+
+	// Concurrency is the maximum number of records created in parallel by
+	// AppendRecords, MergeRecords, SyncManagedRecords and ImportRecordsJSON,
+	// and of zones fetched in parallel by GetRecordsForZones. Values below 2
+	// process them one after another.
+	Concurrency int `json:"concurrency"`
+
+	// DisablePublicSuffix stops using the public suffix list bundled with
+	// this package to limit which parent domains of a name are tried when
+	// looking up its zone. Every parent domain is tried instead, which costs
+	// more requests but works for suffixes missing from the list.
+	DisablePublicSuffix bool `json:"disable_public_suffix"`
+
+	// EffectiveTLDPlusOne, if set, replaces the public suffix list bundled
+	// with this package to find the registrable domain of a name, like
+	// publicsuffix.EffectiveTLDPlusOne, e.g. to use a fresher list or one
+	// with private suffixes. It is ignored if DisablePublicSuffix is set.
+	// If it fails for a name, every parent domain of the name is tried.
+	EffectiveTLDPlusOne func(domain string) (string, error) `json:"-"`
+
+	// PageSize is the number of zones fetched per request when listing
+	// zones, between 5 and 1000 (the default). Larger pages need fewer
+	// requests, smaller pages smaller responses. The records of a zone are
+	// always returned by the API in a single response.
+	PageSize int `json:"page_size"`
+
+	// IdempotentAppend makes AppendRecords skip records that already exist
+	// with the same name, type and data (ignoring the TTL), returning the
+	// existing record instead of creating a duplicate. TXT records, like ACME
+	// challenges, are always appended this way.
+	IdempotentAppend bool `json:"idempotent_append"`
+
+	// ContinueOnError makes AppendRecords, SetRecords, DeleteRecords,
+	// MergeRecords, SyncManagedRecords, ImportRecordsJSON and
+	// CopyZoneRecords process all records even if some of them fail. The
+	// records that were processed successfully are returned together with
+	// the joined errors of the failed ones. By default, processing stops at
+	// the first error.
+	ContinueOnError bool `json:"continue_on_error"`
+
+	// ApexName is how the names of records at the zone apex are returned:
+	// as an empty string (the default), as "@" or as the FQDN of the zone.
+	// Input records may use any of these.
+	ApexName ApexName `json:"apex_name,omitempty"`
+
+	// DefaultTTL is the TTL of records created or updated without a TTL.
+	// If it is not set, a zero TTL is passed on to Bunny.net, which then
+	// applies its own default TTL. Records with TTLAutomatic keep the
+	// automatic TTL of Bunny.net regardless.
+	DefaultTTL time.Duration `json:"default_ttl,omitempty"`
+
+	// AllowedTTLs, if set, are the only TTLs records are written with, in
+	// case Bunny.net rejects other TTLs. Any other TTL is rounded up to the
+	// next allowed TTL, or down to the largest one, and a warning is logged.
+	// RejectDisallowedTTLs makes writing such records fail instead. The
+	// automatic TTL is always allowed.
+	AllowedTTLs          []time.Duration `json:"allowed_ttls,omitempty"`
+	RejectDisallowedTTLs bool            `json:"reject_disallowed_ttls"`
+
+	// BatchSize is the number of records AppendRecords, SetRecords,
+	// MergeRecords, SyncManagedRecords and ImportRecordsJSON process before
+	// pausing for BatchDelay, so that large batches don't overwhelm the API.
+	// It defaults to 100 records and a delay of 1 second. Set BatchDelay to
+	// a negative value to disable the pause.
+	BatchSize  int           `json:"batch_size,omitempty"`
+	BatchDelay time.Duration `json:"batch_delay,omitempty"`
+
+	// RecordTimeout limits the time spent on each record by AppendRecords,
+	// SetRecords, DeleteRecords, MergeRecords, SyncManagedRecords and
+	// ImportRecordsJSON, so that one slow record can't stall the whole
+	// batch. A record that times out fails like any other; together with
+	// ContinueOnError, the remaining records are still processed. The
+	// context passed to these methods still bounds the batch as a whole.
+	RecordTimeout time.Duration `json:"record_timeout,omitempty"`
+
+	// ZoneCacheTTL enables caching the zones looked up by their name, so
+	// that repeated calls for the same zone skip the lookup. Cached zones
+	// are looked up again after ZoneCacheTTL, to pick up zones that were
+	// recreated in the meantime; a negative value caches them forever. By
+	// default, zones are not cached.
+	ZoneCacheTTL time.Duration `json:"zone_cache_ttl,omitempty"`
+
+	// MaxRetries is the number of times a failed request is retried, with a
+	// delay of RetryDelay (1 second by default) that doubles with each
+	// retry, minus a random jitter. If the API sends a Retry-After header,
+	// e.g. when rate limiting, the delay it asks for is used instead. Delays
+	// are capped at MaxRetryDelay (30 seconds by default). By default,
+	// requests are not retried.
+	MaxRetries    int           `json:"max_retries,omitempty"`
+	RetryDelay    time.Duration `json:"retry_delay,omitempty"`
+	MaxRetryDelay time.Duration `json:"max_retry_delay,omitempty"`
+
+	// IsRetryable, if set, decides which failed requests are retried, e.g.
+	// to also retry 404 responses while a new zone is propagated within
+	// Bunny.net. statusCode is the status of the failed response, or 0 if the
+	// request failed without a response. By default, requests failing with a
+	// 429 status are retried, and so are requests failing without a response
+	// or with a 5xx status, unless they create a record, as the record may
+	// have been created anyway.
+	IsRetryable func(statusCode int, err error) bool `json:"-"`
+
+	// MultiMatchStrategy decides what SetRecords does with a record without
+	// ID if the zone has several records with its name and type, none of
+	// which has its data. By default, setting the record fails.
+	MultiMatchStrategy MultiMatchStrategy `json:"multi_match_strategy,omitempty"`
+
+	// ApexCNAMEAsFlatten turns CNAME records at the zone apex, which DNS
+	// forbids, into Bunny.net Flatten records with the same target. By
+	// default, such records are rejected.
+	ApexCNAMEAsFlatten bool `json:"apex_cname_as_flatten"`
+
+	// AllowUnknownCAATags allows CAA records with tags other than issue,
+	// issuewild and iodef, e.g. tags defined by newer RFCs. A warning is
+	// logged for them. By default, such records are rejected.
+	AllowUnknownCAATags bool `json:"allow_unknown_caa_tags"`
+
+	// Headers are added to every request sent to the API, e.g. for tracing
+	// or an API gateway. They can't override the AccessKey, Accept and
+	// Accept-Encoding headers set by the provider.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// HTTPClient, if set, is used to send the requests to the API, e.g. to
+	// use a proxy. Close does not affect it.
+	HTTPClient *http.Client `json:"-"`
+
+	// TLSConfig, if set, configures TLS for the requests to the API, e.g.
+	// to trust the CA of a TLS intercepting proxy or to pin the minimum TLS
+	// version. It is ignored if HTTPClient is set. By default, the TLS
+	// defaults of Go are used.
+	TLSConfig *tls.Config `json:"-"`
+
+	// CheckDelegation makes the methods that create or update records by
+	// the name of their zone fail with ErrZoneNotDelegated, before changing
+	// anything, if Bunny.net did not detect the domain of the zone as
+	// delegated to its nameservers, as the records would not be served, e.g.
+	// to an ACME CA. The status is taken from the zone lookup, so it may be
+	// as old as ZoneCacheTTL. The *InZone methods are never checked.
+	CheckDelegation bool `json:"check_delegation"`
+
+	// ReadOnly makes all methods that would change records or zones fail
+	// with ErrReadOnly, before sending any request, e.g. for inventory tools
+	// that must never change DNS. As a guardrail, only GET requests are sent
+	// to the API. Reading records and zones works as usual.
+	ReadOnly bool `json:"read_only"`
+
+	// OnZoneNotFound, if set, is called when there is no zone for domain,
+	// before failing with ErrZoneNotFound, e.g. to create the zone. The zone
+	// it returns, which must contain domain, is fetched by its ID and used
+	// instead; a zone without ID means there is still no zone.
+	OnZoneNotFound func(ctx context.Context, domain string) (Zone, error) `json:"-"`
+
+	// EventLogger receives structured log events. When set, it takes
+	// precedence over Logger and Debug.
+	EventLogger func(LogEvent) `json:"-"`
+
+	// Trace, if set, is called with every request sent to the API, its
+	// response and the (decompressed) response body, for debugging. The
+	// AccessKey header of the request is redacted. If the request failed
+	// without a response, response and body are nil.
+	Trace func(request *http.Request, response *http.Response, body []byte) `json:"-"`
+
+	mu        sync.Mutex
+	client    *http.Client
+	closed    bool
+	zoneCache map[string]zoneCacheEntry
+	nameLocks map[string]*nameLock
+
+	trimmedAccessKey bool
+}
+
+// EnvironmentVariable is an environment variable passed to the edge script
+// of a Script record.
+type EnvironmentVariable struct {
+	Name  string
+	Value string
+}
+
+// MultiMatchStrategy is the behavior of SetRecords for a record that matches
+// several stored records, see Provider.MultiMatchStrategy.
+type MultiMatchStrategy int
+
+const (
+	// MultiMatchError fails setting the record.
+	MultiMatchError MultiMatchStrategy = iota
+	// MultiMatchUpdateFirst updates the first of the matching records.
+	MultiMatchUpdateFirst
+	// MultiMatchUpdateAll updates all matching records.
+	MultiMatchUpdateAll
+)
+
+// Close releases the resources held by the provider, like the idle
+// connections to the API. The provider is unusable after Close: all further
+// calls fail with ErrClosed.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.zoneCache = nil
+	if p.client != nil {
+		p.client.CloseIdleConnections()
+		p.client = nil
+	}
+
+	return nil
+}
+
+// Record is a libdns.Record annotated with the Bunny.net specific data that
+// has no equivalent in libdns.
+type Record struct {
+	libdns.Record
+
+	// BunnyType is the numeric record type as used by the Bunny.net API.
+	BunnyType int
+
+	// Flags are the record flags. Besides CAA records, where they are also
+	// part of the libdns value, they are preserved when the record is updated.
+	Flags int
+
+	// Accelerated is set if the record is accelerated by the Bunny.net CDN
+	// through the pull zone with the ID PullZoneID. LinkName is the name of
+	// the linked pull zone, if any. These are preserved when the record is
+	// updated.
+	Accelerated bool
+	PullZoneID  int
+	LinkName    string
+
+	// ScriptID is the ID of the edge script a Script record runs, and
+	// EnvironmentVariables are the variables passed to it. These are
+	// preserved when the record is updated.
+	ScriptID             int
+	EnvironmentVariables []EnvironmentVariable
+
+	// MonitorType is the health check monitoring the record, which is
+	// disabled while MonitorStatus is MonitorStatusOffline. It is preserved
+	// when the record is updated; see SetRecordMonitor to change it.
+	MonitorType   MonitorType
+	MonitorStatus MonitorStatus
+
+	// Created and Modified are the times the record was created and last
+	// modified, if returned by the API, or else zero.
+	Created  time.Time
+	Modified time.Time
+
+	// Lossy is set if the record is of a Bunny.net specific type, like
+	// Redirect, Flatten, PullZone or Script, or of a type unknown to this
+	// package, whose data can't be fully represented by libdns.Record. The
+	// data missing from the libdns record is kept when the record is updated
+	// by ID, but lost when the record is re-created from its libdns form.
+	Lossy bool
+
+	// stored is the record as returned by the API, so that an update can
+	// preserve its fields without fetching the zone again.
+	stored *bunnyRecord
 }
 
-// GetRecords lists all the records in the zone.
+// GetRecords lists all the records in the zone. Records with the automatic
+// TTL of Bunny.net are returned with the TTL TTLAutomatic, which is negative,
+// rather than with a zero TTL.
 func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
 	records, err := p.getAllRecords(ctx, unFQDN(zone))
 	if err != nil {
+		p.logError(OperationGetRecords, unFQDN(zone), err)
+		return nil, err
+	}
+	for _, record := range records {
+		p.warnLossy(unFQDN(zone), record)
+	}
+
+	return p.outputRecords(unFQDN(zone), libdnsRecords(records)), nil
+}
+
+// GetRecordsInZone lists all the records in the zone, like GetRecords, but
+// without looking up the zone.
+func (p *Provider) GetRecordsInZone(ctx context.Context, zone Zone) ([]libdns.Record, error) {
+	records, err := p.getZoneRecords(ctx, zone.toBunnyZone())
+	if err != nil {
+		p.logError(OperationGetRecords, unFQDN(zone.Name), err)
 		return nil, err
 	}
+	for _, record := range records {
+		p.warnLossy(unFQDN(zone.Name), record)
+	}
 
-	return records, nil
+	return p.outputRecords(unFQDN(zone.Name), libdnsRecords(records)), nil
 }
 
-// AppendRecords adds records to the zone. It returns the records that were added.
-func (p *Provider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	var appendedRecords []libdns.Record
+// GetRecordsForZones lists all the records in each of the zones, like
+// GetRecords, returning them by zone as given. Up to Concurrency zones are
+// fetched in parallel. A zone that fails does not stop the others: the
+// records of the zones fetched successfully are returned together with the
+// joined errors of the failed ones, each prefixed with its zone. Once ctx is
+// done, the zones not fetched yet fail with the error of ctx.
+func (p *Provider) GetRecordsForZones(ctx context.Context, zones []string) (map[string][]libdns.Record, error) {
+	var unique []string
+	seen := map[string]bool{}
+	for _, zone := range zones {
+		if !seen[zone] {
+			seen[zone] = true
+			unique = append(unique, zone)
+		}
+	}
 
+	var mu sync.Mutex
+	result := map[string][]libdns.Record{}
+	err := forEach(len(unique), p.Concurrency, true, func(i int) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s: %w", unique[i], err)
+		}
+
+		records, err := p.GetRecords(ctx, unique[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", unique[i], err)
+		}
+
+		mu.Lock()
+		result[unique[i]] = records
+		mu.Unlock()
+		return nil
+	})
+
+	return result, err
+}
+
+// GetBunnyRecords lists all the records in the zone, like GetRecords, but
+// keeps the Bunny.net specific data of each record.
+func (p *Provider) GetBunnyRecords(ctx context.Context, zone string) ([]Record, error) {
+	records, err := p.getAllRecords(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetRecords, unFQDN(zone), err)
+		return nil, err
+	}
 	for _, record := range records {
-		newRecord, err := p.createRecord(ctx, unFQDN(zone), record)
+		p.warnLossy(unFQDN(zone), record)
+	}
+
+	return p.outputBunnyRecords(unFQDN(zone), records), nil
+}
+
+// GetRecordsByBunnyType lists the records in the zone with the given numeric
+// Bunny.net record type, e.g. to audit the Bunny.net specific Redirect,
+// PullZone or Script records. Records of types unknown to this package have
+// their numeric type as libdns type.
+func (p *Provider) GetRecordsByBunnyType(ctx context.Context, zone string, bunnyType int) ([]Record, error) {
+	records := []Record{}
+	_, err := p.eachRecord(ctx, unFQDN(zone), func(record Record) bool {
+		if record.BunnyType == bunnyType {
+			records = append(records, record)
+		}
+		return true
+	})
+	if err != nil {
+		p.logError(OperationGetRecords, unFQDN(zone), err)
+		return nil, err
+	}
+
+	return p.outputBunnyRecords(unFQDN(zone), records), nil
+}
+
+// GetRecordsWithParams lists the records in the zone like GetRecords, with
+// the query parameters params added to the request of the zone, e.g. to use
+// filters of the API not supported by this package yet. The parameters are
+// passed through as they are, without validation, and the API ignores the
+// parameters it doesn't know. The records returned are not filtered any
+// further, besides being limited to domain if it is a name within its zone.
+func (p *Provider) GetRecordsWithParams(ctx context.Context, zone string, params url.Values) ([]libdns.Record, error) {
+	domain := unFQDN(zone)
+	resolved, err := p.resolveZone(ctx, domain)
+	if err != nil {
+		p.logError(OperationGetZone, domain, err)
+		return nil, err
+	}
+
+	records := []libdns.Record{}
+	err = p.eachZoneRecordWithParams(ctx, resolved, params, func(record Record) bool {
+		records = append(records, record.Record)
+		return true
+	})
+	if err != nil {
+		p.logError(OperationGetRecords, domain, err)
+		return nil, err
+	}
+
+	return p.outputRecords(domain, records), nil
+}
+
+// GetRecordsByName lists the records in the zone with the given name, e.g.
+// the _acme-challenge records of a domain. The name is relative to the zone
+// or fully-qualified; use "" or "@" for the apex. The API can't filter the
+// records by name, so the records of the zone are filtered as they are read.
+func (p *Provider) GetRecordsByName(ctx context.Context, zone, name string) ([]libdns.Record, error) {
+	domain := unFQDN(zone)
+	name = normalizeName(name, domain)
+	records := []libdns.Record{}
+	_, err := p.eachRecord(ctx, domain, func(record Record) bool {
+		if normalizeName(record.Name, domain) == name {
+			records = append(records, record.Record)
+		}
+		return true
+	})
+	if err != nil {
+		p.logError(OperationGetRecords, domain, err)
+		return nil, err
+	}
+
+	return p.outputRecords(domain, records), nil
+}
+
+// GetRecordsModifiedSince lists the records in the zone that were created or
+// modified after since, e.g. to poll a zone for changes. The API can't filter
+// the records by time, so the records of the zone are filtered as they are
+// read, by their Modified time, or their Created time if the API returned no
+// Modified time. Records without any timestamp are always returned, as they
+// may have changed. Deleted records can't be detected this way.
+func (p *Provider) GetRecordsModifiedSince(ctx context.Context, zone string, since time.Time) ([]Record, error) {
+	records := []Record{}
+	_, err := p.eachRecord(ctx, unFQDN(zone), func(record Record) bool {
+		modified := record.Modified
+		if modified.IsZero() {
+			modified = record.Created
+		}
+		if modified.IsZero() || modified.After(since) {
+			records = append(records, record)
+		}
+		return true
+	})
+	if err != nil {
+		p.logError(OperationGetRecords, unFQDN(zone), err)
+		return nil, err
+	}
+
+	return p.outputBunnyRecords(unFQDN(zone), records), nil
+}
+
+// GetRecordByID returns the record of the zone with the given Bunny.net ID,
+// or ErrRecordNotFound. The API has no endpoint for a single record, so the
+// records of the zone are read until the record is found.
+func (p *Provider) GetRecordByID(ctx context.Context, zone string, id int) (libdns.Record, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return libdns.Record{}, err
+	}
+
+	stored, found, err := p.getZoneRecord(ctx, resolved.ID, fmt.Sprint(id))
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return libdns.Record{}, err
+	}
+	if !found || (resolved.nameBase != "" && !isWithin(stored.Name, resolved.nameBase)) {
+		return libdns.Record{}, fmt.Errorf("%w: %d in zone %s", ErrRecordNotFound, id, unFQDN(zone))
+	}
+
+	record, err := fromBunnyRecord(stored)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err, record.Record)
+	}
+	record.Name = p.outputName(unFQDN(zone), resolved.fromBunnyName(record.Name))
+
+	return record.Record, nil
+}
+
+// AppendRecords adds records to the zone. It returns the records that were
+// added, as returned by the API, including their IDs.
+//
+// The Bunny.net API has no endpoint to create several records at once, so
+// each record is created with its own request. Set Concurrency to send
+// these requests in parallel. Set IdempotentAppend to make retries safe.
+//
+// The records are always created as new records: their IDs are ignored. Use
+// SetRecords to update a record by ID.
+//
+// The records are returned in the order of the input, even if they are
+// created concurrently. With ContinueOnError, the records that failed are
+// left out.
+func (p *Provider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	result, err := p.AppendBunnyRecords(ctx, zone, records)
+	if result == nil {
+		return nil, err
+	}
+	return libdnsRecords(result), err
+}
+
+// AppendBunnyRecords adds records to the zone, like AppendRecords, but
+// returns the added records with the Bunny.net specific data populated by
+// the API.
+func (p *Provider) AppendBunnyRecords(ctx context.Context, zone string, records []libdns.Record) ([]Record, error) {
+	if err := validateCNAMEConflicts(unFQDN(zone), records); err != nil {
+		return nil, err
+	}
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return nil, err
+	}
+
+	result, err := p.appendRecords(ctx, resolved, records)
+	return p.outputBunnyRecords(unFQDN(zone), result), err
+}
+
+// AppendRecordsWithIDs adds records to the zone, like AppendRecords, but
+// returns the ID of the record added for each of the records, by its index,
+// e.g. for tooling that tracks the records it created. With ContinueOnError,
+// the ID of a record that failed is empty. With IdempotentAppend, and for TXT
+// records, the ID of an existing record is returned if the record exists
+// already.
+func (p *Provider) AppendRecordsWithIDs(ctx context.Context, zone string, records []libdns.Record) ([]string, error) {
+	if err := validateCNAMEConflicts(unFQDN(zone), records); err != nil {
+		return nil, err
+	}
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return nil, err
+	}
+
+	appendedRecords, err := p.appendEachRecord(ctx, resolved, records)
+	if err != nil && !p.ContinueOnError {
+		return nil, err
+	}
+
+	ids := make([]string, len(records))
+	for i, appended := range appendedRecords {
+		if len(appended) > 0 {
+			ids[i] = appended[0].ID
+		}
+	}
+	return ids, err
+}
+
+// AppendRecordsInZone adds records to the zone, like AppendRecords, but
+// without looking up the zone.
+func (p *Provider) AppendRecordsInZone(ctx context.Context, zone Zone, records []libdns.Record) ([]libdns.Record, error) {
+	if p.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if err := validateCNAMEConflicts(unFQDN(zone.Name), records); err != nil {
+		return nil, err
+	}
+
+	result, err := p.appendRecords(ctx, zone.toBunnyZone(), records)
+	if result == nil {
+		return nil, err
+	}
+	return p.outputRecords(unFQDN(zone.Name), libdnsRecords(result)), err
+}
+
+func (p *Provider) appendRecords(ctx context.Context, zone bunnyZone, records []libdns.Record) ([]Record, error) {
+	appendedRecords, err := p.appendEachRecord(ctx, zone, records)
+	if err != nil && !p.ContinueOnError {
+		return nil, err
+	}
+
+	return flatten(appendedRecords), err
+}
+
+// appendEachRecord adds the records to the zone, returning the added record
+// for each of them by its index; it is nil for the records that failed.
+func (p *Provider) appendEachRecord(ctx context.Context, zone bunnyZone, records []libdns.Record) ([][]Record, error) {
+	var existingRecords []Record
+	if p.IdempotentAppend || hasTXTRecord(records) {
+		var err error
+		existingRecords, err = p.getZoneRecords(ctx, zone)
 		if err != nil {
+			p.logError(OperationGetRecords, zone.Domain, err)
 			return nil, err
 		}
-		appendedRecords = append(appendedRecords, newRecord)
 	}
 
-	return appendedRecords, nil
+	appendedRecords := make([][]Record, len(records))
+
+	err := p.forEachBatch(ctx, len(records), p.Concurrency, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		for _, existing := range existingRecords {
+			if !p.IdempotentAppend && !isTXTRecord(records[i]) {
+				break
+			}
+			if sameRecord(zone.domain(), existing.Record, records[i]) {
+				p.log(OperationCreateRecord, zone.Domain, fmt.Sprintf("skipping creation of existing %s record %s in zone %s", existing.Type, existing.ID, zone.Domain), existing.Record)
+				appendedRecords[i] = []Record{existing}
+				return nil
+			}
+		}
+
+		newRecord, err := p.createRecord(ctx, zone, records[i])
+		if err != nil {
+			p.logError(OperationCreateRecord, zone.Domain, err, records[i])
+			return err
+		}
+		appendedRecords[i] = []Record{newRecord}
+		return nil
+	})
+
+	return appendedRecords, err
 }
 
 // SetRecords sets the records in the zone, either by updating existing records or creating new ones.
 // It returns the updated records.
+//
+// Records without an ID update the existing record with the same name and
+// type, keeping its ID, e.g. when only the TTL changed. If there is more than
+// one such record, the one with the same data is updated; if none has the
+// same data, MultiMatchStrategy decides. Records that are already stored as
+// given, including their TTL, are not written again.
+//
+// Several records without ID with the same name and type, like the A records
+// of a name with several addresses, set that RRset as a whole: the stored
+// records of the RRset are updated or deleted, and records are created, so
+// that the RRset consists of exactly the given records afterwards.
+//
+// Concurrent calls that set records with the same names wait for each other,
+// so that they don't both create a record that doesn't exist yet.
+//
+// The records are returned in the order of the input. A record that updates
+// several existing records with MultiMatchUpdateAll is returned once for each
+// of them, and with ContinueOnError, the records that failed are left out.
 func (p *Provider) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	var setRecords []libdns.Record
+	if err := validateCNAMEConflicts(unFQDN(zone), records); err != nil {
+		return nil, err
+	}
 
-	for _, record := range records {
-		setRecord, err := p.createOrUpdateRecord(ctx, unFQDN(zone), record)
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return nil, err
+	}
+
+	result, err := p.setRecords(ctx, resolved, records)
+	return p.outputRecords(unFQDN(zone), result), err
+}
+
+// SetRecordsInZone sets the records in the zone, like SetRecords, but
+// without looking up the zone.
+func (p *Provider) SetRecordsInZone(ctx context.Context, zone Zone, records []libdns.Record) ([]libdns.Record, error) {
+	if p.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if err := validateCNAMEConflicts(unFQDN(zone.Name), records); err != nil {
+		return nil, err
+	}
+
+	result, err := p.setRecords(ctx, zone.toBunnyZone(), records)
+	return p.outputRecords(unFQDN(zone.Name), result), err
+}
+
+func (p *Provider) setRecords(ctx context.Context, zone bunnyZone, records []libdns.Record) ([]libdns.Record, error) {
+	unlock, err := p.lockNames(ctx, zone, records)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	existingRecords, err := p.getZoneRecords(ctx, zone)
+	if err != nil {
+		p.logError(OperationGetRecords, zone.Domain, err)
+		return nil, err
+	}
+
+	pairs, leftovers := rrsetPairs(zone, records, existingRecords)
+	setRecords := make([][]libdns.Record, len(records))
+
+	err = p.forEachBatch(ctx, len(records), 1, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		var setRecord []libdns.Record
+		var err error
+		switch pair, ok := pairs[i]; {
+		case !ok:
+			setRecord, err = p.createOrUpdateRecord(ctx, zone, records[i], existingRecords)
+		case pair < 0:
+			var created Record
+			created, err = p.createRecord(ctx, zone, records[i])
+			setRecord = []libdns.Record{created.Record}
+		default:
+			record := records[i]
+			record.ID = existingRecords[pair].ID
+			setRecord, err = p.createOrUpdateRecord(ctx, zone, record, existingRecords)
+		}
 		if err != nil {
-			return setRecords, err
+			op := OperationUpdateRecord
+			if records[i].ID == "" {
+				op = OperationCreateRecord
+			}
+			p.logError(op, zone.Domain, err, records[i])
+			return err
+		}
+		setRecords[i] = setRecord
+		return nil
+	})
+	if err != nil && !p.ContinueOnError {
+		return flatten(setRecords), err
+	}
+
+	// The stored records left over by the RRsets are deleted once the RRsets
+	// are set, so that a name never resolves to no record in between.
+	deleteErr := forEach(len(leftovers), 1, p.ContinueOnError, func(i int) error {
+		if err := p.deleteRecord(ctx, zone, leftovers[i]); err != nil {
+			p.logError(OperationDeleteRecord, zone.Domain, err, leftovers[i])
+			return err
+		}
+		return nil
+	})
+
+	return flatten(setRecords), errors.Join(err, deleteErr)
+}
+
+// rrsetPairs finds the RRsets set by records: the records without ID that
+// share their name and type with another record without ID. Such records are
+// set as a whole, like with DiffRecords: each record is paired with a stored
+// record of the RRset (see pairRecords), and the stored records of the RRset
+// that are not paired are left over, to be deleted. The pairs map the index
+// of each record of an RRset to the index of its stored record in existing,
+// or -1 if it is created.
+func rrsetPairs(zone bunnyZone, records []libdns.Record, existing []Record) (map[int]int, []libdns.Record) {
+	domain := zone.domain()
+	sets := map[string][]int{}
+	var keys []string
+	ids := map[string]bool{}
+	for i, record := range records {
+		if record.ID != "" {
+			ids[record.ID] = true
+			continue
+		}
+		key := strings.ToUpper(record.Type) + " " + normalizeName(record.Name, domain)
+		if sets[key] == nil {
+			keys = append(keys, key)
+		}
+		sets[key] = append(sets[key], i)
+	}
+
+	pairs := map[int]int{}
+	var leftovers []libdns.Record
+	for _, key := range keys {
+		indexes := sets[key]
+		if len(indexes) < 2 {
+			continue
+		}
+
+		desired := make([]libdns.Record, len(indexes))
+		for j, i := range indexes {
+			desired[j] = records[i]
+		}
+		paired := map[int]bool{}
+		for j, pair := range pairRecords(domain, desired, existing) {
+			pairs[indexes[j]] = pair
+			paired[pair] = true
+		}
+
+		for k, candidate := range existing {
+			if paired[k] || ids[candidate.ID] || !sameNameAndType(domain, candidate.Record, desired[0]) {
+				continue
+			}
+			if zone.nameBase == "" && isApexNS(zone.Domain, candidate.Record) {
+				continue
+			}
+			leftovers = append(leftovers, candidate.Record)
 		}
-		setRecords = append(setRecords, setRecord)
 	}
 
-	return setRecords, nil
+	return pairs, leftovers
 }
 
 // DeleteRecords deletes the records from the zone. It returns the records that were deleted.
+//
+// Records without an ID are looked up by their name, type and, if given,
+// value; their TTL is ignored. NS records at the zone apex are managed by
+// Bunny.net and are never deleted; they are skipped and left out of the
+// result. A TXT record is deleted together with any identical duplicates,
+// e.g. of an ACME challenge that was presented twice.
+//
+// The Bunny.net API has no endpoint to delete several records at once, so
+// each record is deleted with its own request, but the records without ID,
+// and the records given only by their ID, are looked up with a single
+// request.
 func (p *Provider) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	if p.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	resolved, err := p.resolveZone(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return nil, err
+	}
+
+	result, err := p.deleteRecords(ctx, resolved, records)
+	return p.outputRecords(unFQDN(zone), result), err
+}
+
+// DeleteRecordsInZone deletes the records from the zone, like DeleteRecords,
+// but without looking up the zone.
+func (p *Provider) DeleteRecordsInZone(ctx context.Context, zone Zone, records []libdns.Record) ([]libdns.Record, error) {
+	if p.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	result, err := p.deleteRecords(ctx, zone.toBunnyZone(), records)
+	return p.outputRecords(unFQDN(zone.Name), result), err
+}
+
+func (p *Provider) deleteRecords(ctx context.Context, zone bunnyZone, records []libdns.Record) ([]libdns.Record, error) {
+	// The API has no endpoint to delete several records at once, so the
+	// records are deleted one by one, but the IDs of records without ID, the
+	// duplicates of TXT records, and the records given only by their ID, are
+	// resolved from a single fetch of the zone.
+	var existingRecords []Record
 	for _, record := range records {
-		err := p.deleteRecord(ctx, unFQDN(zone), record)
-		if err != nil {
-			return nil, err
+		if record.ID == "" || record.Type == "" || isTXTRecord(record) {
+			var err error
+			existingRecords, err = p.getZoneRecords(ctx, zone)
+			if err != nil {
+				p.logError(OperationGetRecords, zone.Domain, err)
+				return nil, err
+			}
+			break
 		}
 	}
 
-	return records, nil
+	deletedRecords := make([][]libdns.Record, len(records))
+	deleted := map[string]bool{}
+
+	err := forEach(len(records), 1, p.ContinueOnError, func(i int) error {
+		ctx, cancel := p.recordContext(ctx)
+		defer cancel()
+
+		record := records[i]
+		apexNS := zone.nameBase == "" && isApexNS(zone.Domain, record)
+		if stored := storedRecord(existingRecords, record.ID); stored != nil {
+			// the API may store the apex as "@"
+			apexNS = stored.Type == bunnyTypeNS && (stored.Name == "" || stored.Name == "@")
+		}
+		if apexNS {
+			p.log(OperationDeleteRecord, zone.Domain, fmt.Sprintf("skipping deletion of apex NS record in zone %s, as it is managed by Bunny.net", zone.Domain), record)
+			return nil
+		}
+
+		// Without an ID, every stored record matching the given one is
+		// deleted. A TXT record is deleted together with its duplicates.
+		matches := []libdns.Record{record}
+		switch {
+		case record.ID == "":
+			matches = matchingRecords(zone.domain(), record, existingRecords)
+		case isTXTRecord(record):
+			matches = append(matches, txtDuplicates(zone.domain(), record, existingRecords)...)
+		}
+
+		for _, match := range matches {
+			if deleted[match.ID] {
+				continue
+			}
+			err := p.deleteRecord(ctx, zone, match)
+			if err != nil {
+				p.logError(OperationDeleteRecord, zone.Domain, err, match)
+				return err
+			}
+			deleted[match.ID] = true
+			deletedRecords[i] = append(deletedRecords[i], match)
+		}
+		return nil
+	})
+	if err != nil && !p.ContinueOnError {
+		return nil, err
+	}
+
+	return flatten(deletedRecords), err
+}
+
+// ApexName is how the names of records at the zone apex are returned.
+type ApexName int
+
+const (
+	// ApexNameEmpty returns apex records with an empty name.
+	ApexNameEmpty ApexName = iota
+	// ApexNameAt returns apex records with the name "@".
+	ApexNameAt
+	// ApexNameFQDN returns apex records with the FQDN of the zone, with a
+	// trailing dot.
+	ApexNameFQDN
+)
+
+// outputName returns name as returned to the caller, which is only changed
+// for apex records, according to ApexName.
+func (p *Provider) outputName(zone, name string) string {
+	if normalizeName(name, zone) != "" {
+		return name
+	}
+
+	switch p.ApexName {
+	case ApexNameAt:
+		return "@"
+	case ApexNameFQDN:
+		return zone + "."
+	default:
+		return ""
+	}
+}
+
+// outputRecords applies outputName to the names of records.
+func (p *Provider) outputRecords(zone string, records []libdns.Record) []libdns.Record {
+	for i := range records {
+		records[i].Name = p.outputName(zone, records[i].Name)
+	}
+	return records
+}
+
+// outputBunnyRecords applies outputName to the names of records.
+func (p *Provider) outputBunnyRecords(zone string, records []Record) []Record {
+	for i := range records {
+		records[i].Name = p.outputName(zone, records[i].Name)
+	}
+	return records
+}
+
+// flatten joins the records processed for each input record of a batch, in
+// the order of the input.
+func flatten[T any](batches [][]T) []T {
+	var result []T
+	for _, batch := range batches {
+		result = append(result, batch...)
+	}
+	return result
+}
+
+// libdnsRecords strips the Bunny.net specific data from records.
+func libdnsRecords(records []Record) []libdns.Record {
+	result := make([]libdns.Record, 0, len(records))
+	for _, record := range records {
+		result = append(result, record.Record)
+	}
+	return result
 }
 
 // unFQDN trims any trailing "." from fqdn. Bunny.net's API does not use FQDNs.
@@ -79,4 +977,5 @@ var (
 	_ libdns.RecordAppender = (*Provider)(nil)
 	_ libdns.RecordSetter   = (*Provider)(nil)
 	_ libdns.RecordDeleter  = (*Provider)(nil)
+	_ libdns.ZoneLister     = (*Provider)(nil)
 )
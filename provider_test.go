@@ -1,3 +1,5 @@
+//go:build integration
+
 package bunny_test
 
 import (
@@ -66,7 +68,7 @@ func TestMain(m *testing.M) {
 		fmt.Println(`Please notice that this test runs agains the public Bunny.net API, so you sould
 never run the test with a zone, used in production.
 To run this test, you have to specify 'BUNNY_TEST_API_KEY' and 'BUNNY_TEST_ZONE'.
-Example: "BUNNY_TEST_API_KEY="123" BUNNY_TEST_ZONE="my-domain.com" go test ./... -v`)
+Example: "BUNNY_TEST_API_KEY="123" BUNNY_TEST_ZONE="my-domain.com" go test -tags integration ./... -v`)
 		os.Exit(1)
 	}
 
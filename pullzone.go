@@ -0,0 +1,38 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CreatePullZoneRecord creates a record of the Bunny.net specific PullZone
+// type, which links name to the pull zone with the given ID, so that it is
+// served by the Bunny.net CDN.
+func (p *Provider) CreatePullZoneRecord(ctx context.Context, zone, name string, pullZoneID int, ttl time.Duration) (Record, error) {
+	if pullZoneID <= 0 {
+		return Record{}, fmt.Errorf("invalid pull zone ID: %d", pullZoneID)
+	}
+
+	p.log(OperationCreateRecord, unFQDN(zone), fmt.Sprintf("creating PullZone record for pull zone %d in zone %s", pullZoneID, unFQDN(zone)))
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return Record{}, err
+	}
+
+	record, err := p.createBunnyRecord(ctx, resolved, bunnyRecord{
+		Type:       bunnyTypePullZone,
+		Name:       name,
+		TTL:        p.bunnyTTL(ttl),
+		PullZoneID: pullZoneID,
+	})
+	if err != nil {
+		p.logError(OperationCreateRecord, unFQDN(zone), err)
+		return Record{}, err
+	}
+
+	record.Name = p.outputName(unFQDN(zone), record.Name)
+	return record, nil
+}
@@ -0,0 +1,73 @@
+package bunny
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_AcceleratedRecordRoundTrip(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeCNAME, Name: "cdn", Value: "origin.example.net", TTL: 120, Accelerated: true, AcceleratedPullZoneID: 5},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetBunnyRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Accelerated || records[0].PullZoneID != 5 {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+
+	record := records[0].Record
+	record.Value = "origin2.example.net"
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := zone.Records[0]
+	if stored.Value != "origin2.example.net" || !stored.Accelerated || stored.AcceleratedPullZoneID != 5 {
+		t.Fatalf("unexpected stored record => %+v", stored)
+	}
+}
+
+func Test_CreatePullZoneRecord(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	record, err := p.CreatePullZoneRecord(context.TODO(), "example.com.", "cdn", 7, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Type != "PullZone" || record.PullZoneID != 7 || record.ID == "" {
+		t.Fatalf("unexpected record => %+v", record)
+	}
+	if zone.Records[0].PullZoneID != 7 {
+		t.Fatalf("zone.Records[0].PullZoneID != 7 => %d", zone.Records[0].PullZoneID)
+	}
+
+	if _, err := p.CreatePullZoneRecord(context.TODO(), "example.com.", "cdn", 0, ttl); err == nil {
+		t.Fatal("expected an error for an invalid pull zone ID")
+	}
+
+	// The TTL is sent like with AppendRecords
+	p.DefaultTTL = 5 * time.Minute
+	if _, err := p.CreatePullZoneRecord(context.TODO(), "example.com.", "cdn2", 7, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CreatePullZoneRecord(context.TODO(), "example.com.", "cdn3", 7, TTLAutomatic); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[1].TTL != 300 || zone.Records[2].TTL != 0 {
+		t.Fatalf("unexpected TTLs => %d, %d", zone.Records[1].TTL, zone.Records[2].TTL)
+	}
+}
@@ -0,0 +1,74 @@
+package bunny
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetZoneRaw returns the zone that contains domain as returned by the API,
+// including its records, without any conversion, e.g. to compare the data
+// of the API against the records returned by GetRecords. The access key is
+// only sent in a request header, so it is never part of the response.
+func (p *Provider) GetZoneRaw(ctx context.Context, domain string) (json.RawMessage, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(domain))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return nil, err
+	}
+
+	data, err := p.getZoneRaw(ctx, resolved.ID)
+	if err != nil {
+		p.logError(OperationGetZone, resolved.Domain, err)
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// GetRecordsRaw returns the records of the zone that contains domain as
+// returned by the API, like GetZoneRaw. All records of the zone are
+// returned, even if domain is a name within the zone.
+func (p *Provider) GetRecordsRaw(ctx context.Context, domain string) (json.RawMessage, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(domain))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return nil, err
+	}
+
+	data, err := p.getZoneRaw(ctx, resolved.ID)
+	if err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return nil, err
+	}
+
+	result := struct {
+		Records json.RawMessage `json:"Records"`
+	}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		p.logError(OperationGetRecords, resolved.Domain, err)
+		return nil, err
+	}
+	if result.Records == nil {
+		return json.RawMessage("[]"), nil
+	}
+
+	return result.Records, nil
+}
+
+// getZoneRaw fetches the zone object with the given ID.
+func (p *Provider) getZoneRaw(ctx context.Context, id int) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		fmt.Sprintf("%s/dnszone/%d", apiBaseURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := p.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(data), nil
+}
@@ -0,0 +1,45 @@
+package bunny
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_GetRaw(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "secret-key"}
+	zone, err := p.GetZoneRaw(context.TODO(), "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedZone := map[string]any{}
+	if err := json.Unmarshal(zone, &parsedZone); err != nil {
+		t.Fatal(err)
+	}
+	if parsedZone["Domain"] != "example.com" {
+		t.Fatalf("unexpected zone => %s", zone)
+	}
+	if strings.Contains(string(zone), "secret-key") {
+		t.Fatalf("the access key is part of the zone => %s", zone)
+	}
+
+	records, err := p.GetRecordsRaw(context.TODO(), "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedRecords := []map[string]any{}
+	if err := json.Unmarshal(records, &parsedRecords); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsedRecords) != 1 || parsedRecords[0]["Id"] != float64(10) || parsedRecords[0]["Value"] != "test" {
+		t.Fatalf("unexpected records => %s", records)
+	}
+}
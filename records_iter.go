@@ -0,0 +1,30 @@
+//go:build go1.23
+
+package bunny
+
+import (
+	"context"
+	"iter"
+
+	"github.com/libdns/libdns"
+)
+
+// RecordsSeq iterates over the records in the zone, like GetRecords, but
+// yields each record as it is decoded from the API response instead of
+// building the full list in memory. If fetching the records fails, the
+// error is yielded last, with an empty record.
+func (p *Provider) RecordsSeq(ctx context.Context, zone string) iter.Seq2[libdns.Record, error] {
+	return func(yield func(libdns.Record, error) bool) {
+		stopped := false
+		_, err := p.eachRecord(ctx, unFQDN(zone), func(record Record) bool {
+			p.warnLossy(unFQDN(zone), record)
+			record.Name = p.outputName(unFQDN(zone), record.Name)
+			stopped = !yield(record.Record, nil)
+			return !stopped
+		})
+		if err != nil && !stopped {
+			p.logError(OperationGetRecords, unFQDN(zone), err)
+			yield(libdns.Record{}, err)
+		}
+	}
+}
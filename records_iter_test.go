@@ -0,0 +1,44 @@
+//go:build go1.23
+
+package bunny
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_RecordsSeq(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "test1", Value: "test1", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "test2", Value: "test2", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "test3", Value: "test3", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+
+	var names []string
+	for record, err := range p.RecordsSeq(context.TODO(), "example.com") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, record.Name)
+		if len(names) == 2 {
+			break
+		}
+	}
+
+	if len(names) != 2 || names[0] != "test1" || names[1] != "test2" {
+		t.Fatalf("names != [test1 test2] => %v", names)
+	}
+
+	var lastErr error
+	for _, err := range p.RecordsSeq(context.TODO(), "unknown.org") {
+		lastErr = err
+	}
+	if lastErr == nil {
+		t.Fatal("expected an error for an unknown zone")
+	}
+}
@@ -0,0 +1,122 @@
+package bunny
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The defaults of Provider.RetryDelay and Provider.MaxRetryDelay.
+const (
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = 30 * time.Second
+)
+
+// isRetryable reports whether the failed request should be retried, see
+// Provider.IsRetryable. statusCode is 0 if the request failed without a
+// response.
+func (p *Provider) isRetryable(request *http.Request, statusCode int, err error) bool {
+	if request.Context().Err() != nil {
+		return false
+	}
+	// A request with a body can only be sent again if the body can be
+	// recreated.
+	if request.Body != nil && request.GetBody == nil {
+		return false
+	}
+
+	if p.IsRetryable != nil {
+		return p.IsRetryable(statusCode, err)
+	}
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	// The server may have processed a request that failed without a response
+	// or with a server error, so only requests that can be sent twice are
+	// retried.
+	return (statusCode == 0 || statusCode >= 500) && isIdempotent(request)
+}
+
+// isIdempotent reports whether sending the request twice has the same effect
+// as sending it once. Unlike in HTTP in general, a PUT request creates a
+// record with the Bunny.net API, so it would create the record twice; the
+// requests with other methods read, update or delete.
+func isIdempotent(request *http.Request) bool {
+	return request.Method != http.MethodPut
+}
+
+// waitForRetry waits before the given retry of the request, see retryDelay,
+// and rewinds the body of the request. failed is the failed response, if
+// any.
+func (p *Provider) waitForRetry(request *http.Request, attempt int, failed *http.Response) error {
+	timer := time.NewTimer(p.retryDelay(attempt, failed, time.Now()))
+	select {
+	case <-request.Context().Done():
+		timer.Stop()
+		return request.Context().Err()
+	case <-timer.C:
+	}
+
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return err
+		}
+		request.Body = body
+	}
+
+	return nil
+}
+
+// retryDelay returns the delay before the given retry: the delay requested
+// by the Retry-After header of the failed response, if any, or else
+// RetryDelay, doubled with each attempt, with a random jitter of up to half
+// of it. The delay is capped at MaxRetryDelay.
+func (p *Provider) retryDelay(attempt int, failed *http.Response, now time.Time) time.Duration {
+	maxDelay := p.MaxRetryDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxRetryDelay
+	}
+
+	if failed != nil {
+		if delay, ok := parseRetryAfter(failed.Header.Get("Retry-After"), now); ok {
+			if delay > maxDelay {
+				return maxDelay
+			}
+			return delay
+		}
+	}
+
+	delay := p.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	delay <<= attempt
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay - time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
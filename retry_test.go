@@ -0,0 +1,215 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// flakyAPI fails the first failures requests with the given status before
+// passing requests on to the mock API.
+type flakyAPI struct {
+	*mockAPI
+	status     int
+	failures   int
+	retryAfter string
+}
+
+func (f *flakyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	fail := f.failures > 0
+	if fail {
+		f.failures--
+	}
+	retryAfter := f.retryAfter
+	f.mu.Unlock()
+
+	if fail {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(f.status)
+		return
+	}
+	f.mockAPI.ServeHTTP(w, r)
+}
+
+func newFlakyAPI(t *testing.T, status, failures int) *flakyAPI {
+	f := &flakyAPI{
+		mockAPI:  &mockAPI{zones: []*mockZone{{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}}, nextID: 1000},
+		status:   status,
+		failures: failures,
+	}
+	serveTestAPI(t, f)
+	return f
+}
+
+func Test_Retries(t *testing.T) {
+	newFlakyAPI(t, http.StatusServiceUnavailable, 2)
+
+	p := &Provider{AccessKey: "key", MaxRetries: 2, RetryDelay: time.Millisecond}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The body of a request is sent again
+	f := newFlakyAPI(t, http.StatusTooManyRequests, 1)
+	records, err := p.AppendRecordsInZone(context.TODO(), Zone{ID: 1}, []libdns.Record{{Type: "A", Name: "test", Value: "1.2.3.4", TTL: ttl}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(f.zones[0].Records) != 1 || f.zones[0].Records[0].Value != "1.2.3.4" {
+		t.Fatalf("unexpected records => %+v", f.zones[0].Records)
+	}
+
+	// A record may have been created by a request that failed with a server
+	// error, so creations are not retried
+	f = newFlakyAPI(t, http.StatusBadGateway, 1)
+	if _, err := p.AppendRecordsInZone(context.TODO(), Zone{ID: 1}, []libdns.Record{{Type: "A", Name: "test", Value: "1.2.3.4", TTL: ttl}}); err == nil {
+		t.Fatal("expected an error for a 502 response to a creation")
+	}
+	if count := f.requestCount("PUT"); count != 0 {
+		t.Fatalf("creation retried => %d", count)
+	}
+
+	// Deletions are
+	f = newFlakyAPI(t, http.StatusBadGateway, 1)
+	f.zones[0].Records = []bunnyRecord{{ID: 10, Type: bunnyTypeA, Name: "test", Value: "1.2.3.4", TTL: 120}}
+	if _, err := p.DeleteRecordsInZone(context.TODO(), Zone{ID: 1}, []libdns.Record{{ID: "10", Type: "A", Name: "test", Value: "1.2.3.4"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.zones[0].Records) != 0 {
+		t.Fatalf("unexpected records => %+v", f.zones[0].Records)
+	}
+
+	// Client errors are not retried by default
+	newFlakyAPI(t, http.StatusNotFound, 1)
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err == nil {
+		t.Fatalf("expected an error for a 404 response")
+	}
+}
+
+func Test_IsRetryable(t *testing.T) {
+	f := newFlakyAPI(t, http.StatusNotFound, 1)
+
+	var statusCodes []int
+	p := &Provider{
+		AccessKey:  "key",
+		MaxRetries: 1,
+		RetryDelay: time.Millisecond,
+		IsRetryable: func(statusCode int, err error) bool {
+			statusCodes = append(statusCodes, statusCode)
+			return statusCode == http.StatusNotFound
+		},
+	}
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(statusCodes) != 1 || statusCodes[0] != http.StatusNotFound {
+		t.Fatalf("unexpected status codes => %v", statusCodes)
+	}
+
+	// A server error is not retried if the classifier says so
+	f.mu.Lock()
+	f.status, f.failures = http.StatusInternalServerError, 1
+	f.mu.Unlock()
+	if _, err := p.GetRecords(context.TODO(), "example.com"); err == nil {
+		t.Fatalf("expected an error for a 500 response")
+	}
+}
+
+func Test_RetryAfter(t *testing.T) {
+	f := newFlakyAPI(t, http.StatusTooManyRequests, 1)
+	f.mu.Lock()
+	f.retryAfter = "0"
+	f.mu.Unlock()
+
+	// The requested delay is used instead of RetryDelay
+	p := &Provider{AccessKey: "key", MaxRetries: 1, RetryDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	if _, err := p.GetRecords(ctx, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, c := range testCases {
+		delay, ok := parseRetryAfter(c.value, now)
+		if delay != c.expected || ok != c.ok {
+			t.Fatalf("parseRetryAfter(%q) != %s, %t => %s, %t", c.value, c.expected, c.ok, delay, ok)
+		}
+	}
+}
+
+func Test_retryDelay(t *testing.T) {
+	now := time.Now()
+	p := &Provider{RetryDelay: 100 * time.Millisecond, MaxRetryDelay: time.Minute}
+
+	// Retry-After is honored, up to MaxRetryDelay
+	failed := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"10"}}}
+	if delay := p.retryDelay(0, failed, now); delay != 10*time.Second {
+		t.Fatalf("delay != 10s => %s", delay)
+	}
+	failed.Header.Set("Retry-After", "3600")
+	if delay := p.retryDelay(0, failed, now); delay != time.Minute {
+		t.Fatalf("delay != 1m => %s", delay)
+	}
+
+	// Without Retry-After, the delay doubles with each attempt, with jitter
+	failed = &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		if delay := p.retryDelay(attempt, failed, now); delay < max/2 || delay > max {
+			t.Fatalf("delay of attempt %d not in [%s, %s] => %s", attempt, max/2, max, delay)
+		}
+	}
+	if delay := p.retryDelay(20, nil, now); delay < 30*time.Second || delay > time.Minute {
+		t.Fatalf("delay not capped at 1m => %s", delay)
+	}
+}
+
+func Test_RateLimitedError(t *testing.T) {
+	f := newFlakyAPI(t, http.StatusTooManyRequests, 1)
+	f.mu.Lock()
+	f.retryAfter = "7"
+	f.mu.Unlock()
+
+	p := &Provider{AccessKey: "key"}
+	_, err := p.GetRecords(context.TODO(), "example.com")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError => %v", err)
+	}
+	if !apiErr.IsRateLimited() {
+		t.Fatalf("apiErr.IsRateLimited() != true => %d", apiErr.StatusCode)
+	}
+	if apiErr.RetryAfter != 7*time.Second {
+		t.Fatalf("apiErr.RetryAfter != 7s => %s", apiErr.RetryAfter)
+	}
+
+	// Other failures are not rate limits
+	newFlakyAPI(t, http.StatusServiceUnavailable, 1)
+	_, err = p.GetRecords(context.TODO(), "example.com")
+	if !errors.As(err, &apiErr) || apiErr.IsRateLimited() || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected error => %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package bunny
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CreateScriptRecord creates a record of the Bunny.net specific Script type,
+// which answers queries for name with the edge script with the given ID,
+// passing it the given environment variables.
+func (p *Provider) CreateScriptRecord(ctx context.Context, zone, name string, scriptID int, variables []EnvironmentVariable, ttl time.Duration) (Record, error) {
+	if scriptID <= 0 {
+		return Record{}, fmt.Errorf("invalid script ID: %d", scriptID)
+	}
+
+	p.log(OperationCreateRecord, unFQDN(zone), fmt.Sprintf("creating Script record for script %d in zone %s", scriptID, unFQDN(zone)))
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return Record{}, err
+	}
+
+	reqData := bunnyRecord{
+		Type:     bunnyTypeScript,
+		Name:     name,
+		TTL:      p.bunnyTTL(ttl),
+		ScriptID: scriptID,
+	}
+	for _, variable := range variables {
+		reqData.EnvironmentVariables = append(reqData.EnvironmentVariables, bunnyEnvVariable(variable))
+	}
+
+	record, err := p.createBunnyRecord(ctx, resolved, reqData)
+	if err != nil {
+		p.logError(OperationCreateRecord, unFQDN(zone), err)
+		return Record{}, err
+	}
+
+	record.Name = p.outputName(unFQDN(zone), record.Name)
+	return record, nil
+}
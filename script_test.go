@@ -0,0 +1,149 @@
+package bunny
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_ScriptRecordRoundTrip(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeScript, Name: "edge", TTL: 120, ScriptID: 42, EnvironmentVariables: []bunnyEnvVariable{
+				{Name: "ORIGIN", Value: "origin.example.net"},
+				{Name: "MODE", Value: "strict"},
+			}},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetBunnyRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Type != "Script" || records[0].ScriptID != 42 || len(records[0].EnvironmentVariables) != 2 {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if variable := records[0].EnvironmentVariables[0]; variable.Name != "ORIGIN" || variable.Value != "origin.example.net" {
+		t.Fatalf("unexpected environment variable => %+v", variable)
+	}
+
+	record := records[0].Record
+	record.TTL = 2 * ttl
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := zone.Records[0]
+	if stored.TTL != 240 || stored.ScriptID != 42 || len(stored.EnvironmentVariables) != 2 || stored.EnvironmentVariables[1].Value != "strict" {
+		t.Fatalf("unexpected stored record => %+v", stored)
+	}
+}
+
+func Test_CreateScriptRecord(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	record, err := p.CreateScriptRecord(context.TODO(), "example.com.", "edge", 42, []EnvironmentVariable{{Name: "MODE", Value: "strict"}}, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Type != "Script" || record.ScriptID != 42 || len(record.EnvironmentVariables) != 1 || record.ID == "" {
+		t.Fatalf("unexpected record => %+v", record)
+	}
+	if zone.Records[0].ScriptID != 42 || zone.Records[0].EnvironmentVariables[0].Name != "MODE" {
+		t.Fatalf("unexpected stored record => %+v", zone.Records[0])
+	}
+
+	if _, err := p.CreateScriptRecord(context.TODO(), "example.com.", "edge", 0, nil, ttl); err == nil {
+		t.Fatal("expected an error for an invalid script ID")
+	}
+
+	// The TTL is sent like with AppendRecords
+	p.DefaultTTL = 5 * time.Minute
+	if _, err := p.CreateScriptRecord(context.TODO(), "example.com.", "edge2", 42, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CreateScriptRecord(context.TODO(), "example.com.", "edge3", 42, nil, TTLAutomatic); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[1].TTL != 300 || zone.Records[2].TTL != 0 {
+		t.Fatalf("unexpected TTLs => %d, %d", zone.Records[1].TTL, zone.Records[2].TTL)
+	}
+}
+
+// payloadRecorder records the bodies of the record updates sent to the mock
+// API.
+type payloadRecorder struct {
+	*mockAPI
+	payloads []map[string]any
+}
+
+func (r *payloadRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost && strings.Contains(req.URL.Path, "/records/") {
+		body, _ := io.ReadAll(req.Body)
+		payload := map[string]any{}
+		json.Unmarshal(body, &payload)
+		r.mu.Lock()
+		r.payloads = append(r.payloads, payload)
+		r.mu.Unlock()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	r.mockAPI.ServeHTTP(w, req)
+}
+
+func Test_UpdatePayloads(t *testing.T) {
+	r := &payloadRecorder{mockAPI: &mockAPI{nextID: 1000, zones: []*mockZone{{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeRedirect, Name: "old", Value: "https://example.com/old", TTL: 120},
+			{ID: 11, Type: bunnyTypeScript, Name: "edge", TTL: 120, ScriptID: 42, EnvironmentVariables: []bunnyEnvVariable{{Name: "MODE", Value: "strict"}}},
+		},
+	}}}}
+	serveTestAPI(t, r)
+
+	p := &Provider{AccessKey: "key"}
+	_, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{ID: "10", Type: "Redirect", Name: "old", Value: "https://example.com/new?a=1&b=2", TTL: ttl},
+		{ID: "11", Type: "Script", Name: "edge", TTL: 2 * ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.payloads) != 2 {
+		t.Fatalf("len(r.payloads) != 2 => %d", len(r.payloads))
+	}
+
+	redirect := r.payloads[0]
+	if redirect["Type"] != float64(bunnyTypeRedirect) || redirect["Value"] != "https://example.com/new?a=1&b=2" || redirect["Ttl"] != float64(120) {
+		t.Fatalf("unexpected Redirect payload => %v", redirect)
+	}
+
+	script := r.payloads[1]
+	variables, _ := script["EnviromentalVariables"].([]any)
+	if script["Type"] != float64(bunnyTypeScript) || script["ScriptId"] != float64(42) || script["Ttl"] != float64(240) || len(variables) != 1 {
+		t.Fatalf("unexpected Script payload => %v", script)
+	}
+
+	// a record changed to another type loses the fields of its old type
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{ID: "11", Type: "TXT", Name: "edge", Value: "static", TTL: ttl},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	txt := r.payloads[2]
+	if _, ok := txt["ScriptId"]; ok || txt["EnviromentalVariables"] != nil || txt["Value"] != "static" {
+		t.Fatalf("unexpected TXT payload => %v", txt)
+	}
+}
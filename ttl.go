@@ -0,0 +1,115 @@
+package bunny
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TTLAutomatic is the TTL of records whose TTL is chosen by Bunny.net, the
+// "Automatic" TTL of the dashboard, which the API represents as a TTL of 0.
+// Records with the automatic TTL are returned with TTLAutomatic rather than
+// with a zero TTL, so that they keep it when they are set again: a record
+// with TTLAutomatic is always sent with the automatic TTL, even if
+// DefaultTTL is set, while a record without TTL gets DefaultTTL.
+//
+// The TTLs map to the TTLs of the API as follows:
+//
+//   - TTLAutomatic, or any other negative TTL, is sent as 0.
+//   - A zero TTL is sent as DefaultTTL, or else as 0.
+//   - Any other TTL is sent in whole seconds.
+//   - A TTL of 0 of the API is returned as TTLAutomatic, any other TTL in
+//     seconds.
+const TTLAutomatic time.Duration = -1
+
+// bunnyTTL returns the TTL in seconds to send to the API for a record with
+// the given TTL. A zero TTL is sent as is, letting Bunny.net apply its
+// default TTL, unless DefaultTTL is set.
+func (p *Provider) bunnyTTL(ttl time.Duration) int {
+	if ttl == 0 {
+		ttl = p.DefaultTTL
+	}
+	return toBunnyTTL(ttl)
+}
+
+// ttlMatches reports whether a record with the given TTL has the TTL of a
+// record stored with the TTL stored. A record without TTL matches any TTL,
+// unless DefaultTTL is set.
+func (p *Provider) ttlMatches(ttl, stored time.Duration) bool {
+	if ttl == 0 && p.DefaultTTL == 0 {
+		return true
+	}
+	bunnyTTL := p.bunnyTTL(ttl)
+	if !p.RejectDisallowedTTLs {
+		bunnyTTL, _ = p.snapTTL(bunnyTTL)
+	}
+	return bunnyTTL == toBunnyTTL(stored)
+}
+
+// snapTTL returns the smallest of AllowedTTLs that is at least the TTL in
+// seconds of the API, or the largest one if the TTL exceeds them all, and
+// whether the TTL is allowed as is. The automatic TTL is always allowed, as
+// are all TTLs if AllowedTTLs is not set.
+func (p *Provider) snapTTL(ttl int) (int, bool) {
+	if ttl == 0 || len(p.AllowedTTLs) == 0 {
+		return ttl, true
+	}
+
+	allowed := make([]int, len(p.AllowedTTLs))
+	for i, allowedTTL := range p.AllowedTTLs {
+		allowed[i] = toBunnyTTL(allowedTTL)
+	}
+	sort.Ints(allowed)
+
+	i := sort.SearchInts(allowed, ttl)
+	if i == len(allowed) {
+		return allowed[i-1], false
+	}
+	return allowed[i], allowed[i] == ttl
+}
+
+// checkTTL ensures the TTL of a record is one of AllowedTTLs. A TTL that is
+// not is snapped to an allowed one by snapTTL and a warning is logged, unless
+// RejectDisallowedTTLs is set, in which case it is rejected.
+func (p *Provider) checkTTL(op, zone string, record *bunnyRecord) error {
+	snapped, ok := p.snapTTL(record.TTL)
+	if ok {
+		return nil
+	}
+
+	if p.RejectDisallowedTTLs {
+		return fmt.Errorf("TTL %ds of %s not allowed; expected one of %v", record.TTL, record.Name, p.AllowedTTLs)
+	}
+
+	p.log(op, zone, fmt.Sprintf("warning: TTL %ds of %s not allowed, using %ds instead", record.TTL, record.Name, snapped))
+	record.TTL = snapped
+	return nil
+}
+
+// toBunnyTTL converts a TTL to the TTL in seconds of the API, where 0 is the
+// automatic TTL.
+func toBunnyTTL(ttl time.Duration) int {
+	if ttl < 0 {
+		return 0
+	}
+	return int(ttl.Seconds())
+}
+
+// fromBunnyTTL converts a TTL in seconds of the API to a TTL.
+func fromBunnyTTL(ttl int) time.Duration {
+	if ttl <= 0 {
+		return TTLAutomatic
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// checkReturnedTTL logs a warning if the API returned a record with another
+// TTL than requested, e.g. as it clamped the TTL to its minimum, so that the
+// caller can tell why the TTL didn't stick.
+func (p *Provider) checkReturnedTTL(op, zone string, requested, returned bunnyRecord) {
+	if requested.TTL == 0 || returned.TTL == requested.TTL {
+		return
+	}
+
+	p.log(op, zone, fmt.Sprintf("warning: TTL %ds of %s changed to %ds by Bunny.net", requested.TTL, requested.Name, returned.TTL))
+}
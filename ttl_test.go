@@ -0,0 +1,254 @@
+package bunny
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_ZeroTTL(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	// By default, Bunny.net decides
+	p := &Provider{AccessKey: "key"}
+	records, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[0].TTL != 0 {
+		t.Fatalf("zone.Records[0].TTL != 0 => %d", zone.Records[0].TTL)
+	}
+
+	p.DefaultTTL = 10 * time.Minute
+	if _, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "other"}}); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[1].TTL != 600 {
+		t.Fatalf("zone.Records[1].TTL != 600 => %d", zone.Records[1].TTL)
+	}
+
+	// An explicit TTL is kept, and updates apply the default as well
+	if _, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "ttl", TTL: ttl}}); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[2].TTL != 120 {
+		t.Fatalf("zone.Records[2].TTL != 120 => %d", zone.Records[2].TTL)
+	}
+	records[0].TTL = 0
+	if _, err := p.SetRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[0].TTL != 600 {
+		t.Fatalf("zone.Records[0].TTL != 600 => %d", zone.Records[0].TTL)
+	}
+}
+
+func Test_TTLAutomatic(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "auto", Value: "auto", TTL: 0},
+			{ID: 11, Type: bunnyTypeTXT, Name: "fixed", Value: "fixed", TTL: 120},
+		},
+	}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key", DefaultTTL: 10 * time.Minute}
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].TTL != TTLAutomatic || records[1].TTL != 2*time.Minute {
+		t.Fatalf("unexpected TTLs => %s, %s", records[0].TTL, records[1].TTL)
+	}
+
+	// the automatic TTL round-trips, despite DefaultTTL
+	if _, err := p.SetRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+	if count := m.requestCount("POST"); count != 0 {
+		t.Fatalf("m.requestCount(\"POST\") != 0 => %d", count)
+	}
+
+	// a fixed TTL can be made automatic, and the other way round
+	records[0].TTL, records[1].TTL = 5*time.Minute, TTLAutomatic
+	if _, err := p.SetRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[0].TTL != 300 || zone.Records[1].TTL != 0 {
+		t.Fatalf("unexpected TTLs => %d, %d", zone.Records[0].TTL, zone.Records[1].TTL)
+	}
+
+	// other negative TTLs are automatic too, and a zero TTL gets DefaultTTL
+	created, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "negative", Value: "negative", TTL: -time.Hour},
+		{Type: "TXT", Name: "zero", Value: "zero"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[2].TTL != 0 || zone.Records[3].TTL != 600 {
+		t.Fatalf("unexpected TTLs => %d, %d", zone.Records[2].TTL, zone.Records[3].TTL)
+	}
+	if created[0].TTL != TTLAutomatic || created[1].TTL != 10*time.Minute {
+		t.Fatalf("unexpected TTLs => %s, %s", created[0].TTL, created[1].TTL)
+	}
+}
+
+func Test_AllowedTTLs(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	var messages []string
+	p := &Provider{
+		AccessKey:   "key",
+		AllowedTTLs: []time.Duration{time.Hour, 5 * time.Minute, time.Minute},
+		Logger:      func(msg string, _ []libdns.Record) { messages = append(messages, msg) },
+	}
+
+	for _, c := range []struct {
+		ttl      time.Duration
+		expected int
+	}{
+		{time.Minute, 60},
+		{90 * time.Second, 300},
+		{2 * time.Hour, 3600},
+		{TTLAutomatic, 0},
+	} {
+		records, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: c.ttl.String(), TTL: c.ttl}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bunnyTTL := toBunnyTTL(records[0].TTL); bunnyTTL != c.expected {
+			t.Fatalf("TTL of %s != %d => %d", c.ttl, c.expected, bunnyTTL)
+		}
+	}
+
+	warnings := 0
+	for _, msg := range messages {
+		if strings.HasPrefix(msg, "warning: TTL") {
+			warnings++
+		}
+	}
+	if warnings != 2 {
+		t.Fatalf("warnings != 2 => %d", warnings)
+	}
+
+	// A snapped TTL matches the stored one, so the record is not rewritten
+	messages = nil
+	if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "1m30s", TTL: 90 * time.Second}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range messages {
+		if strings.HasPrefix(msg, "updating") {
+			t.Fatalf("unexpected update => %s", msg)
+		}
+	}
+}
+
+func Test_RejectDisallowedTTLs(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key", AllowedTTLs: []time.Duration{time.Minute, time.Hour}, RejectDisallowedTTLs: true}
+	if _, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "test", TTL: 2 * time.Minute}}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(zone.Records) != 0 {
+		t.Fatalf("len(zone.Records) != 0 => %d", len(zone.Records))
+	}
+
+	if _, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "test", TTL: time.Hour}}); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[0].TTL != 3600 {
+		t.Fatalf("zone.Records[0].TTL != 3600 => %d", zone.Records[0].TTL)
+	}
+}
+
+func Test_ClampedTTL(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	m := &mockAPI{zones: []*mockZone{zone}, nextID: 1000}
+
+	// The API clamps TTLs to at least 5 minutes, and answers updates with the
+	// updated record.
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut && r.Method != http.MethodPost {
+			m.ServeHTTP(w, r)
+			return
+		}
+
+		record := bunnyRecord{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if record.TTL > 0 && record.TTL < 300 {
+			record.TTL = 300
+		}
+		body, _ := json.Marshal(record)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, r)
+		if r.Method == http.MethodPost && recorder.Code == http.StatusNoContent {
+			record.ID = zone.Records[0].ID
+			writeJSON(w, record)
+			return
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	}))
+
+	var messages []string
+	p := &Provider{
+		AccessKey: "key",
+		Logger:    func(msg string, _ []libdns.Record) { messages = append(messages, msg) },
+	}
+	warnings := func() int {
+		n := 0
+		for _, msg := range messages {
+			if strings.HasPrefix(msg, "warning: TTL") {
+				n++
+			}
+		}
+		return n
+	}
+
+	records, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "test", Value: "test", TTL: time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].TTL != 5*time.Minute {
+		t.Fatalf("records[0].TTL != 5m => %s", records[0].TTL)
+	}
+	if warnings() != 1 {
+		t.Fatalf("warnings() != 1 => %q", messages)
+	}
+
+	records[0].TTL = 2 * time.Minute
+	if _, err := p.SetRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+	if warnings() != 2 {
+		t.Fatalf("warnings() != 2 => %q", messages)
+	}
+
+	// a TTL that is not clamped is not reported
+	records[0].TTL = time.Hour
+	if _, err := p.SetRecords(context.TODO(), "example.com", records); err != nil {
+		t.Fatal(err)
+	}
+	if warnings() != 2 {
+		t.Fatalf("warnings() != 2 => %q", messages)
+	}
+}
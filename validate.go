@@ -0,0 +1,91 @@
+package bunny
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// normalizeName returns the lower-cased name of a record relative to zone,
+// with the zone apex represented as an empty string.
+func normalizeName(name, zone string) string {
+	name = strings.ToLower(relativeName(name, zone))
+	if name == "@" {
+		return ""
+	}
+	return name
+}
+
+// validateCNAMEConflicts ensures that a CNAME record is the only record at
+// its name within a batch of records, as required by DNS.
+func validateCNAMEConflicts(zone string, records []libdns.Record) error {
+	typesByName := map[string][]string{}
+	for _, record := range records {
+		name := normalizeName(record.Name, zone)
+		typesByName[name] = append(typesByName[name], record.Type)
+	}
+
+	for _, record := range records {
+		if record.Type != "CNAME" {
+			continue
+		}
+		name := normalizeName(record.Name, zone)
+		if types := typesByName[name]; len(types) > 1 {
+			if name == "" {
+				name = "@"
+			}
+			return fmt.Errorf("conflicting records at %s: a CNAME record must be the only record at its name, found %s",
+				name, strings.Join(types, ", "))
+		}
+	}
+
+	return nil
+}
+
+// isApexNS reports whether record is an NS record at the apex of zone. These
+// are managed by Bunny.net and must not be deleted.
+func isApexNS(zone string, record libdns.Record) bool {
+	return record.Type == "NS" && normalizeName(record.Name, zone) == ""
+}
+
+// knownCAATags are the CAA property tags defined by RFC 8659.
+var knownCAATags = map[string]bool{
+	"issue":     true,
+	"issuewild": true,
+	"iodef":     true,
+}
+
+// checkCAATag ensures the tag of a CAA record is a known one. Unknown tags
+// are rejected, unless AllowUnknownCAATags is set, in which case a warning is
+// logged.
+func (p *Provider) checkCAATag(op, zone string, record bunnyRecord) error {
+	if record.Type != bunnyTypeCAA || knownCAATags[strings.ToLower(record.Tag)] {
+		return nil
+	}
+
+	if !p.AllowUnknownCAATags {
+		return fmt.Errorf("unknown CAA tag %q for %s; expected one of issue, issuewild or iodef", record.Tag, record.Name)
+	}
+
+	p.log(op, zone, fmt.Sprintf("warning: unknown CAA tag %q for %s", record.Tag, record.Name))
+	return nil
+}
+
+// checkApexCNAME ensures a CNAME record is not at the apex of the zone, where
+// DNS forbids it. If ApexCNAMEAsFlatten is set, such a record is turned into
+// a Flatten record instead, which Bunny.net resolves to the addresses of its
+// target.
+func (p *Provider) checkApexCNAME(op string, zone bunnyZone, record *bunnyRecord) error {
+	if record.Type != bunnyTypeCNAME || zone.toBunnyName(record.Name) != "" {
+		return nil
+	}
+
+	if !p.ApexCNAMEAsFlatten {
+		return fmt.Errorf("CNAME record not allowed at the apex of zone %s; use a Flatten record, or set ApexCNAMEAsFlatten", zone.Domain)
+	}
+
+	p.log(op, zone.Domain, fmt.Sprintf("creating Flatten record instead of CNAME record at the apex of zone %s", zone.Domain))
+	record.Type = bunnyTypeFlatten
+	return nil
+}
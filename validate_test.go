@@ -0,0 +1,240 @@
+package bunny
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func Test_validateCNAMEConflicts(t *testing.T) {
+	testCases := []struct {
+		records []libdns.Record
+		wantErr bool
+	}{
+		{
+			records: []libdns.Record{
+				{Type: "CNAME", Name: "www", Value: "example.net."},
+				{Type: "A", Name: "api", Value: "127.0.0.1"},
+			},
+		},
+		{
+			// CNAME plus another type at the same name
+			records: []libdns.Record{
+				{Type: "CNAME", Name: "www", Value: "example.net."},
+				{Type: "A", Name: "www", Value: "127.0.0.1"},
+			},
+			wantErr: true,
+		},
+		{
+			// names are compared case-insensitively and relative to the zone
+			records: []libdns.Record{
+				{Type: "TXT", Name: "WWW.example.com.", Value: "test"},
+				{Type: "CNAME", Name: "www", Value: "example.net."},
+			},
+			wantErr: true,
+		},
+		{
+			// more than one CNAME at the same name
+			records: []libdns.Record{
+				{Type: "CNAME", Name: "www", Value: "example.net."},
+				{Type: "CNAME", Name: "www", Value: "example.org."},
+			},
+			wantErr: true,
+		},
+	}
+
+	for k, c := range testCases {
+		err := validateCNAMEConflicts("example.com", c.records)
+		if c.wantErr && err == nil {
+			t.Fatalf("testCases[%d]: expected an error", k)
+		}
+		if !c.wantErr && err != nil {
+			t.Fatalf("testCases[%d]: unexpected error: %v", k, err)
+		}
+	}
+}
+
+func Test_SetRecordsCNAMEConflict(t *testing.T) {
+	m := newMockAPI(t, &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}})
+
+	p := &Provider{AccessKey: "key"}
+	_, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "CNAME", Name: "www", Value: "example.net.", TTL: ttl},
+		{Type: "A", Name: "www", Value: "127.0.0.1", TTL: ttl},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a CNAME conflict")
+	}
+
+	if count := len(m.requests); count != 0 {
+		t.Fatalf("len(m.requests) != 0 => %d", count)
+	}
+}
+
+func Test_DeleteRecordsKeepsApexNS(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeNS, Name: "", Value: "kiki.bunny.net", TTL: 120},
+			{ID: 11, Type: bunnyTypeNS, Name: "sub", Value: "ns1.example.net", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := p.DeleteRecords(context.TODO(), "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 2 {
+		t.Fatalf("len(deleted) != 2 => %d", len(deleted))
+	}
+	if len(zone.Records) != 1 || zone.Records[0].ID != 10 {
+		t.Fatalf("apex NS record not preserved => %+v", zone.Records)
+	}
+
+	// a record given only by its ID is checked with the stored record
+	deleted, err = p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{{ID: "10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 0 || len(zone.Records) != 1 {
+		t.Fatalf("apex NS record not preserved => %+v", zone.Records)
+	}
+
+	// the apex may be stored as "@"
+	zone.Records[0].Name = "@"
+	deleted, err = p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{{ID: "10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 0 || len(zone.Records) != 1 {
+		t.Fatalf("apex NS record stored as @ not preserved => %+v", zone.Records)
+	}
+}
+
+func Test_CAATagValidation(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	var messages []string
+	p := &Provider{
+		AccessKey: "key",
+		Logger:    func(msg string, _ []libdns.Record) { messages = append(messages, msg) },
+	}
+
+	_, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "CAA", Name: "", Value: `0 ISSUE "letsencrypt.org"`, TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := []libdns.Record{{Type: "CAA", Name: "", Value: `0 issuemail "example.net"`, TTL: ttl}}
+	if _, err := p.AppendRecords(context.TODO(), "example.com", unknown); err == nil {
+		t.Fatal("expected an error for an unknown CAA tag")
+	}
+	if len(zone.Records) != 1 {
+		t.Fatalf("len(zone.Records) != 1 => %d", len(zone.Records))
+	}
+
+	p.AllowUnknownCAATags = true
+	if _, err := p.AppendRecords(context.TODO(), "example.com", unknown); err != nil {
+		t.Fatal(err)
+	}
+
+	warned := false
+	for _, msg := range messages {
+		if strings.HasPrefix(msg, "warning: unknown CAA tag") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Fatal("expected a warning for an unknown CAA tag")
+	}
+}
+
+func Test_ApexCNAMEAsFlatten(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	apex := []libdns.Record{{Type: "CNAME", Name: "@", Value: "target.example.net.", TTL: ttl}}
+	_, err := p.AppendRecords(context.TODO(), "example.com", apex)
+	if err == nil || !strings.Contains(err.Error(), "Flatten") {
+		t.Fatalf("expected an error suggesting Flatten => %v", err)
+	}
+	if len(zone.Records) != 0 {
+		t.Fatalf("len(zone.Records) != 0 => %d", len(zone.Records))
+	}
+
+	// the apex of a subdomain is not the apex of the zone
+	if _, err := p.AppendRecords(context.TODO(), "www.example.com", apex); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 1 || zone.Records[0].Type != bunnyTypeCNAME || zone.Records[0].Name != "www" {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+
+	p.ApexCNAMEAsFlatten = true
+	records, err := p.AppendRecords(context.TODO(), "example.com", apex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Type != "Flatten" || records[0].Value != "target.example.net." {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if len(zone.Records) != 2 || zone.Records[1].Type != bunnyTypeFlatten || zone.Records[1].Name != "" {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+
+	// updates are converted too
+	_, err = p.SetRecords(context.TODO(), "example.com", []libdns.Record{{ID: records[0].ID, Type: "CNAME", Name: "", Value: "other.example.net.", TTL: ttl}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[1].Type != bunnyTypeFlatten || zone.Records[1].Value != "other.example.net." {
+		t.Fatalf("unexpected zone record => %+v", zone.Records[1])
+	}
+}
+
+func Test_NamesIgnoreCase(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "1.2.3.4", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone)
+
+	if name := normalizeName("WWW.Example.com.", "example.com"); name != "www" {
+		t.Fatalf("name != www => %s", name)
+	}
+
+	p := &Provider{AccessKey: "key"}
+	for i := 0; i < 2; i++ {
+		if _, err := p.SetRecords(context.TODO(), "example.com", []libdns.Record{{Type: "A", Name: "WWW.Example.com.", Value: "5.6.7.8", TTL: ttl}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(zone.Records) != 1 || zone.Records[0].ID != 10 || zone.Records[0].Value != "5.6.7.8" {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+
+	deleted, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{{Type: "A", Name: "WWW.EXAMPLE.COM."}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || len(zone.Records) != 0 {
+		t.Fatalf("unexpected zone records => %+v", zone.Records)
+	}
+}
@@ -0,0 +1,412 @@
+package bunny
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// Zone is a libdns.Zone annotated with its Bunny.net ID. It can be passed to
+// the *InZone methods, which skip looking up the zone by its name and use the
+// ID directly. If only the ID is known, e.g. from the Bunny.net dashboard, the
+// name may be left empty, as long as record names are relative to the zone;
+// GetZoneByID returns the zone including its name.
+type Zone struct {
+	libdns.Zone
+
+	// ID is the ID of the zone at Bunny.net.
+	ID int
+}
+
+// toBunnyZone returns the zone as used internally.
+func (z Zone) toBunnyZone() bunnyZone {
+	return bunnyZone{ID: z.ID, Domain: unFQDN(z.Name)}
+}
+
+// GetZone returns the zone that contains domain, which may be the zone
+// itself or any name within it.
+func (p *Provider) GetZone(ctx context.Context, domain string) (Zone, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(domain))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return Zone{}, err
+	}
+
+	return Zone{Zone: libdns.Zone{Name: resolved.Domain + "."}, ID: resolved.ID}, nil
+}
+
+// GetZoneByID returns the zone with the given Bunny.net ID, without
+// searching for it by its name.
+func (p *Provider) GetZoneByID(ctx context.Context, id int) (Zone, error) {
+	result, err := p.fetchZone(ctx, id)
+	if err != nil {
+		p.logError(OperationGetZone, fmt.Sprint(id), err)
+		return Zone{}, err
+	}
+
+	return Zone{Zone: libdns.Zone{Name: result.Domain + "."}, ID: result.ID}, nil
+}
+
+// GetNameservers returns the hostnames of the nameservers the zone, or the
+// zone of a name within it, is configured to use. These are either
+// Bunny.net's own nameservers or, if enabled for the zone, its custom
+// (vanity) nameservers.
+func (p *Provider) GetNameservers(ctx context.Context, zone string) ([]string, error) {
+	result, err := p.resolveZone(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return nil, err
+	}
+
+	return result.nameservers(), nil
+}
+
+// nameservers returns the hostnames of the nameservers of the zone.
+func (z bunnyZone) nameservers() []string {
+	nameservers := []string{}
+	for _, nameserver := range []string{z.Nameserver1, z.Nameserver2} {
+		if nameserver != "" {
+			nameservers = append(nameservers, nameserver)
+		}
+	}
+	return nameservers
+}
+
+// DelegationStatus is whether the domain of a zone is delegated to the
+// nameservers of the zone, as detected by the periodic checks of Bunny.net.
+// Until it is, the records of the zone are not served, so DNS challenges,
+// e.g. of ACME certificate issuance, fail.
+type DelegationStatus struct {
+	// Delegated is set if Bunny.net detected the nameservers of the zone as
+	// the nameservers of its domain.
+	Delegated bool
+	// NextCheck is the time of the next check, or zero if not known.
+	NextCheck time.Time
+	// Nameservers are the nameservers the domain must be delegated to.
+	Nameservers []string
+}
+
+// GetDelegationStatus returns the delegation status of the zone that
+// contains domain. The zone is fetched anew, as the status changes over time.
+func (p *Provider) GetDelegationStatus(ctx context.Context, domain string) (DelegationStatus, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(domain))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return DelegationStatus{}, err
+	}
+
+	result, err := p.fetchZone(ctx, resolved.ID)
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return DelegationStatus{}, err
+	}
+
+	status := DelegationStatus{Delegated: result.NameserversDetected, Nameservers: result.nameservers()}
+	if result.NameserversNextCheck != nil {
+		status.NextCheck = result.NameserversNextCheck.Time
+	}
+
+	return status, nil
+}
+
+// ErrZoneNotDelegated is returned when records are about to be created or
+// updated in a zone whose domain Bunny.net did not detect as delegated to
+// its nameservers, if CheckDelegation is set.
+var ErrZoneNotDelegated = errors.New("zone is not delegated to its Bunny.net nameservers")
+
+// resolveZoneForWrite resolves the zone of domain like resolveZone, before
+// records are created or updated in it. It fails with ErrReadOnly if ReadOnly
+// is set, without looking up the zone. If CheckDelegation is set, it fails
+// with ErrZoneNotDelegated unless the domain of the zone is delegated to its
+// nameservers, as reported with the zone lookup.
+func (p *Provider) resolveZoneForWrite(ctx context.Context, domain string) (bunnyZone, error) {
+	if p.ReadOnly {
+		return bunnyZone{}, ErrReadOnly
+	}
+
+	zone, err := p.resolveZone(ctx, domain)
+	if err != nil || !p.CheckDelegation || zone.NameserversDetected {
+		return zone, err
+	}
+
+	err = fmt.Errorf("%w: %s", ErrZoneNotDelegated, zone.Domain)
+	if nameservers := zone.nameservers(); len(nameservers) > 0 {
+		err = fmt.Errorf("%w; delegate it to %s", err, strings.Join(nameservers, " and "))
+	}
+	return bunnyZone{}, err
+}
+
+// ZoneRecordCount returns the number of records of the zone that contains
+// domain, as embedded in the zone object found by the zone lookup, without
+// fetching and converting the records themselves. The count covers the whole
+// Bunny.net zone, even if domain is a name within it.
+func (p *Provider) ZoneRecordCount(ctx context.Context, domain string) (int, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(domain))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(domain), err)
+		return 0, err
+	}
+
+	return int(resolved.RecordCount), nil
+}
+
+// recordCount decodes the "Records" array of a zone object as the number of
+// its elements.
+type recordCount int
+
+func (c *recordCount) UnmarshalJSON(data []byte) error {
+	var records []json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+	*c = recordCount(len(records))
+	return nil
+}
+
+// The bounds of the page size accepted by the API.
+const (
+	minPageSize     = 5
+	maxPageSize     = 1000
+	defaultPageSize = maxPageSize
+)
+
+// pageSize returns the number of items to fetch per page.
+func (p *Provider) pageSize() (int, error) {
+	if p.PageSize == 0 {
+		return defaultPageSize, nil
+	}
+	if p.PageSize < minPageSize || p.PageSize > maxPageSize {
+		return 0, fmt.Errorf("page size must be between %d and %d: %d", minPageSize, maxPageSize, p.PageSize)
+	}
+	return p.PageSize, nil
+}
+
+// listZones fetches all zones whose domain contains search, following the
+// pagination of the API.
+func (p *Provider) listZones(ctx context.Context, search string) ([]bunnyZone, error) {
+	p.log(OperationListZones, search, "listing zones")
+
+	perPage, err := p.pageSize()
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []bunnyZone{}
+	for page := 1; ; page++ {
+		req, err := http.NewRequestWithContext(ctx, "GET",
+			fmt.Sprintf("%s/dnszone?page=%d&perPage=%d&search=%s", apiBaseURL, page, perPage, url.QueryEscape(search)), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := p.doRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		result := getAllZonesResponse{}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+
+		zones = append(zones, result.Zones...)
+		if !result.HasMoreItems || len(result.Zones) == 0 {
+			break
+		}
+	}
+
+	p.log(OperationListZones, search, fmt.Sprintf("done listing %d zone(s)", len(zones)))
+
+	return zones, nil
+}
+
+// ListZones returns all DNS zones of the account.
+func (p *Provider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	zones, err := p.listZones(ctx, "")
+	if err != nil {
+		p.logError(OperationListZones, "", err)
+		return nil, err
+	}
+
+	return libdnsZones(zones), nil
+}
+
+// libdnsZones converts zones to libdns zones, named by their FQDN.
+func libdnsZones(zones []bunnyZone) []libdns.Zone {
+	result := make([]libdns.Zone, 0, len(zones))
+	for _, zone := range zones {
+		result = append(result, libdns.Zone{Name: zone.Domain + "."})
+	}
+	return result
+}
+
+// ListZonesMatching returns the DNS zones of the account whose domain
+// contains substring, as filtered by the API.
+func (p *Provider) ListZonesMatching(ctx context.Context, substring string) ([]libdns.Zone, error) {
+	zones, err := p.listZones(ctx, unFQDN(substring))
+	if err != nil {
+		p.logError(OperationListZones, unFQDN(substring), err)
+		return nil, err
+	}
+
+	return libdnsZones(zones), nil
+}
+
+// GetAllRecords returns the records of every zone of the account, keyed by
+// the domain of the zone. A zone whose records can't be fetched is left out
+// of the result and its error is included in the returned error, so that one
+// failing zone does not abort the whole enumeration.
+func (p *Provider) GetAllRecords(ctx context.Context) (map[string][]libdns.Record, error) {
+	zones, err := p.listZones(ctx, "")
+	if err != nil {
+		p.logError(OperationListZones, "", err)
+		return nil, err
+	}
+
+	result := map[string][]libdns.Record{}
+	var errs []error
+	for _, zone := range zones {
+		if err := ctx.Err(); err != nil {
+			return result, errors.Join(append(errs, err)...)
+		}
+
+		records := []libdns.Record{}
+		err := p.eachZoneRecord(ctx, zone, func(record Record) bool {
+			records = append(records, record.Record)
+			return true
+		})
+		if err != nil {
+			p.logError(OperationGetRecords, zone.Domain, err)
+			errs = append(errs, fmt.Errorf("%s: %w", zone.Domain, err))
+			continue
+		}
+
+		result[zone.Domain] = p.outputRecords(zone.Domain, records)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// fetchZone fetches the current state of the zone with the given ID.
+func (p *Provider) fetchZone(ctx context.Context, id int) (bunnyZone, error) {
+	data, err := p.getZoneRaw(ctx, id)
+	if err != nil {
+		return bunnyZone{}, err
+	}
+
+	result := bunnyZone{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return bunnyZone{}, err
+	}
+
+	return result, nil
+}
+
+// updateZone updates the zone with the given ID with the fields of update.
+func (p *Provider) updateZone(ctx context.Context, id int, update any) error {
+	reqBuffer, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		fmt.Sprintf("%s/dnszone/%d", apiBaseURL, id), bytes.NewBuffer(reqBuffer))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("content-type", "application/json")
+	_, err = p.doRequest(req)
+	return err
+}
+
+// LogAnonymizationType controls how client IP addresses are anonymized in the
+// DNS query logs of a zone.
+type LogAnonymizationType int
+
+const (
+	// LogAnonymizationOneDigit removes the last digit of the IP address.
+	LogAnonymizationOneDigit LogAnonymizationType = 0
+	// LogAnonymizationDrop removes the IP address entirely.
+	LogAnonymizationDrop LogAnonymizationType = 1
+)
+
+// ZoneSettings are the Bunny.net specific settings of a zone.
+type ZoneSettings struct {
+	// LoggingEnabled enables the DNS query logs of the zone.
+	LoggingEnabled bool
+	// LogIPAnonymization enables anonymizing client IP addresses in the logs.
+	LogIPAnonymization bool
+	// LogAnonymizationType is how IP addresses are anonymized, if enabled.
+	LogAnonymizationType LogAnonymizationType
+}
+
+type zoneSettingsRequest struct {
+	LoggingEnabled                bool `json:"LoggingEnabled"`
+	LoggingIPAnonymizationEnabled bool `json:"LoggingIPAnonymizationEnabled"`
+	LogAnonymizationType          int  `json:"LogAnonymizationType"`
+}
+
+// GetZoneSettings returns the Bunny.net specific settings of the zone, or of
+// the zone of a name within it.
+func (p *Provider) GetZoneSettings(ctx context.Context, zone string) (ZoneSettings, error) {
+	resolved, err := p.resolveZone(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return ZoneSettings{}, err
+	}
+
+	result, err := p.fetchZone(ctx, resolved.ID)
+	if err != nil {
+		p.logError(OperationGetZone, unFQDN(zone), err)
+		return ZoneSettings{}, err
+	}
+
+	return ZoneSettings{
+		LoggingEnabled:       result.LoggingEnabled,
+		LogIPAnonymization:   result.LoggingIPAnonymizationEnabled,
+		LogAnonymizationType: LogAnonymizationType(result.LogAnonymizationType),
+	}, nil
+}
+
+// SetZoneSettings updates the Bunny.net specific settings of the zone.
+func (p *Provider) SetZoneSettings(ctx context.Context, zone string, settings ZoneSettings) error {
+	if p.ReadOnly {
+		return ErrReadOnly
+	}
+	switch settings.LogAnonymizationType {
+	case LogAnonymizationOneDigit, LogAnonymizationDrop:
+	default:
+		return fmt.Errorf("invalid log anonymization type: %d", settings.LogAnonymizationType)
+	}
+
+	p.log(OperationUpdateZone, unFQDN(zone), fmt.Sprintf("updating settings of zone %s", unFQDN(zone)))
+
+	resolved, err := p.resolveZoneForWrite(ctx, unFQDN(zone))
+	if err != nil {
+		p.logError(OperationUpdateZone, unFQDN(zone), err)
+		return err
+	}
+
+	err = p.updateZone(ctx, resolved.ID, zoneSettingsRequest{
+		LoggingEnabled:                settings.LoggingEnabled,
+		LoggingIPAnonymizationEnabled: settings.LogIPAnonymization,
+		LogAnonymizationType:          int(settings.LogAnonymizationType),
+	})
+	if err != nil {
+		p.logError(OperationUpdateZone, unFQDN(zone), err)
+		return err
+	}
+
+	p.log(OperationUpdateZone, unFQDN(zone), fmt.Sprintf("done updating settings of zone %s", unFQDN(zone)))
+
+	return nil
+}
@@ -0,0 +1,993 @@
+package bunny
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"golang.org/x/net/publicsuffix"
+)
+
+func Test_GetNameservers(t *testing.T) {
+	newMockAPI(t, &mockZone{bunnyZone: bunnyZone{
+		ID:                       1,
+		Domain:                   "example.com",
+		CustomNameserversEnabled: true,
+		Nameserver1:              "ns1.example.net",
+		Nameserver2:              "ns2.example.net",
+	}})
+
+	p := &Provider{AccessKey: "key"}
+	nameservers, err := p.GetNameservers(context.TODO(), "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"ns1.example.net", "ns2.example.net"}
+	if len(nameservers) != len(expected) {
+		t.Fatalf("len(nameservers) != len(expected) => %d != %d", len(nameservers), len(expected))
+	}
+	for k, nameserver := range nameservers {
+		if nameserver != expected[k] {
+			t.Fatalf("nameservers[%d] != expected[%d] => %s != %s", k, k, nameserver, expected[k])
+		}
+	}
+
+	// A name within the zone resolves to the zone
+	nameservers, err = p.GetNameservers(context.TODO(), "www.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nameservers) != len(expected) {
+		t.Fatalf("len(nameservers) != len(expected) => %d != %d", len(nameservers), len(expected))
+	}
+}
+
+func Test_GetDelegationStatus(t *testing.T) {
+	detected := false
+	serveTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		zone := fmt.Sprintf(`{"Id":1,"Domain":"example.com","Nameserver1":"kiki.bunny.net","Nameserver2":"coco.bunny.net","NameserversDetected":%t,"NameserversNextCheck":"2024-05-01T10:00:00","Records":[]}`, detected)
+		switch r.URL.Path {
+		case "/dnszone":
+			fmt.Fprintf(w, `{"Items":[%s]}`, zone)
+		case "/dnszone/1":
+			fmt.Fprint(w, zone)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	p := &Provider{AccessKey: "key"}
+	status, err := p.GetDelegationStatus(context.TODO(), "_acme-challenge.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Delegated || len(status.Nameservers) != 2 || status.Nameservers[0] != "kiki.bunny.net" {
+		t.Fatalf("unexpected status => %+v", status)
+	}
+	if !status.NextCheck.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next check => %s", status.NextCheck)
+	}
+
+	detected = true
+	status, err = p.GetDelegationStatus(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Delegated {
+		t.Fatalf("unexpected status => %+v", status)
+	}
+}
+
+func Test_CheckDelegation(t *testing.T) {
+	undelegated := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com", Nameserver1: "kiki.bunny.net", Nameserver2: "coco.bunny.net"}}
+	delegated := &mockZone{bunnyZone: bunnyZone{ID: 2, Domain: "example.org", NameserversDetected: true}}
+	m := newMockAPI(t, undelegated, delegated)
+
+	p := &Provider{AccessKey: "key", CheckDelegation: true}
+	challenge := []libdns.Record{{Type: "TXT", Name: "_acme-challenge", Value: "token", TTL: ttl}}
+	_, err := p.AppendRecords(context.TODO(), "example.com", challenge)
+	if !errors.Is(err, ErrZoneNotDelegated) {
+		t.Fatalf("expected ErrZoneNotDelegated => %v", err)
+	}
+	if !strings.Contains(err.Error(), "kiki.bunny.net and coco.bunny.net") {
+		t.Fatalf("expected the nameservers in the error => %v", err)
+	}
+	if _, err := p.SetRecords(context.TODO(), "example.com", challenge); !errors.Is(err, ErrZoneNotDelegated) {
+		t.Fatalf("expected ErrZoneNotDelegated => %v", err)
+	}
+	if count := m.requestCount("PUT"); count != 0 {
+		t.Fatalf("m.requestCount(\"PUT\") != 0 => %d", count)
+	}
+
+	if _, err := p.AppendRecords(context.TODO(), "example.org", challenge); err != nil {
+		t.Fatal(err)
+	}
+
+	// deletions are not checked, so that challenges can be cleaned up
+	undelegated.Records = []bunnyRecord{{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token", TTL: 120}}
+	if _, err := p.DeleteRecords(context.TODO(), "example.com", challenge); err != nil {
+		t.Fatal(err)
+	}
+
+	p.CheckDelegation = false
+	if _, err := p.AppendRecords(context.TODO(), "example.com", challenge); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_getBaseDomainNameGuesses(t *testing.T) {
+	testCases := []struct {
+		domain   string
+		expected []string
+	}{
+		{"example.com", []string{"example.com"}},
+		{"_acme-challenge.example.com", []string{"_acme-challenge.example.com", "example.com"}},
+		{"_acme-challenge.sub.example.com.", []string{"_acme-challenge.sub.example.com", "sub.example.com", "example.com"}},
+		{"_acme-challenge._foo.example.co.uk", []string{"_acme-challenge._foo.example.co.uk", "_foo.example.co.uk", "example.co.uk"}},
+		{"localhost", []string{"localhost"}},
+		// "co.uk" is a public suffix, so it has no registrable domain
+		{"co.uk", []string{"co.uk", "uk"}},
+	}
+
+	for _, c := range testCases {
+		guesses := getBaseDomainNameGuesses(c.domain, publicsuffix.EffectiveTLDPlusOne)
+		if strings.Join(guesses, ",") != strings.Join(c.expected, ",") {
+			t.Fatalf("getBaseDomainNameGuesses(%q) != c.expected => %v != %v", c.domain, guesses, c.expected)
+		}
+	}
+}
+
+func Test_GetRecordsUnderscoreLabels(t *testing.T) {
+	newMockAPI(t,
+		&mockZone{
+			bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+			Records: []bunnyRecord{
+				{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token1", TTL: 120},
+				{ID: 11, Type: bunnyTypeTXT, Name: "_acme-challenge.www", Value: "token2", TTL: 120},
+				{ID: 12, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+			},
+		},
+		&mockZone{
+			bunnyZone: bunnyZone{ID: 2, Domain: "sub.example.com"},
+			Records: []bunnyRecord{
+				{ID: 20, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token3", TTL: 120},
+			},
+		},
+	)
+
+	p := &Provider{AccessKey: "key"}
+
+	testCases := []struct {
+		domain   string
+		expected []string
+	}{
+		{"_acme-challenge.example.com.", []string{"10"}},
+		{"_acme-challenge.www.example.com", []string{"11"}},
+		{"www.example.com", []string{"11", "12"}},
+		// delegated sub zones are preferred over their parent zone
+		{"_acme-challenge.sub.example.com", []string{"20"}},
+	}
+
+	for _, c := range testCases {
+		records, err := p.GetRecords(context.TODO(), c.domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []string
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(c.expected, ",") {
+			t.Fatalf("GetRecords(%q) IDs != c.expected => %v != %v", c.domain, ids, c.expected)
+		}
+	}
+
+	if _, err := p.GetRecords(context.TODO(), "_acme-challenge.unknown.org"); !errors.Is(err, ErrZoneNotFound) {
+		t.Fatalf("expected ErrZoneNotFound, got %v", err)
+	}
+}
+
+func Test_DisablePublicSuffix(t *testing.T) {
+	guesses := getBaseDomainNameGuesses("_acme-challenge.example.co.uk", nil)
+	expected := []string{"_acme-challenge.example.co.uk", "example.co.uk", "co.uk", "uk"}
+	if strings.Join(guesses, ",") != strings.Join(expected, ",") {
+		t.Fatalf("guesses != expected => %v != %v", guesses, expected)
+	}
+
+	// "co.uk" is a public suffix, so it is only found when the public
+	// suffix list is disabled.
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "co.uk"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge.example", Value: "token", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	if _, err := p.GetRecords(context.TODO(), "_acme-challenge.example.co.uk"); !errors.Is(err, ErrZoneNotFound) {
+		t.Fatalf("expected ErrZoneNotFound, got %v", err)
+	}
+
+	p.DisablePublicSuffix = true
+	records, err := p.GetRecords(context.TODO(), "_acme-challenge.example.co.uk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "10" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+}
+
+func Test_EffectiveTLDPlusOneError(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "internal"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge.www", Value: "token", TTL: 120},
+		},
+	})
+
+	p := &Provider{
+		AccessKey: "key",
+		EffectiveTLDPlusOne: func(domain string) (string, error) {
+			return "", fmt.Errorf("no public suffix for %s", domain)
+		},
+	}
+	records, err := p.GetRecords(context.TODO(), "_acme-challenge.www.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "10" || records[0].Name != "" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+}
+
+func Test_EffectiveTLDPlusOne(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "customer.example.net"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeTXT, Name: "_acme-challenge", Value: "token", TTL: 120},
+		},
+	})
+
+	// "example.net" is a private suffix unknown to the bundled list
+	var domains []string
+	p := &Provider{AccessKey: "key", EffectiveTLDPlusOne: func(domain string) (string, error) {
+		domains = append(domains, domain)
+		if strings.HasSuffix(domain, ".example.net") {
+			labels := strings.Split(domain, ".")
+			return strings.Join(labels[len(labels)-3:], "."), nil
+		}
+		return publicsuffix.EffectiveTLDPlusOne(domain)
+	}}
+	records, err := p.GetRecords(context.TODO(), "_acme-challenge.customer.example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != "10" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+	if len(domains) != 1 || domains[0] != "_acme-challenge.customer.example.net" {
+		t.Fatalf("unexpected calls => %v", domains)
+	}
+
+	// the parent of the registrable domain is never guessed
+	guesses := getBaseDomainNameGuesses("_acme-challenge.customer.example.net", p.EffectiveTLDPlusOne)
+	expected := []string{"_acme-challenge.customer.example.net", "customer.example.net"}
+	if strings.Join(guesses, ",") != strings.Join(expected, ",") {
+		t.Fatalf("guesses != expected => %v != %v", guesses, expected)
+	}
+}
+
+func Test_ListZones(t *testing.T) {
+	var zones []*mockZone
+	for i := 1; i <= 250; i++ {
+		zones = append(zones, &mockZone{bunnyZone: bunnyZone{ID: i, Domain: fmt.Sprintf("example%d.com", i)}})
+	}
+	m := newMockAPI(t, zones...)
+
+	p := &Provider{AccessKey: "key", PageSize: 100}
+	result, err := p.ListZones(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != len(zones) {
+		t.Fatalf("len(result) != len(zones) => %d != %d", len(result), len(zones))
+	}
+	if result[0].Name != "example1.com." {
+		t.Fatalf(`result[0].Name != "example1.com." => %s`, result[0].Name)
+	}
+	if count := m.requestCount(http.MethodGet); count != 3 {
+		t.Fatalf("GET requests != 3 => %d", count)
+	}
+}
+
+func Test_ListZonesMatching(t *testing.T) {
+	var zones []*mockZone
+	for i := 1; i <= 12; i++ {
+		zones = append(zones, &mockZone{bunnyZone: bunnyZone{ID: i, Domain: fmt.Sprintf("example%d.com", i)}})
+	}
+	zones = append(zones, &mockZone{bunnyZone: bunnyZone{ID: 13, Domain: "other.org"}})
+	m := newMockAPI(t, zones...)
+
+	p := &Provider{AccessKey: "key", PageSize: 5}
+	result, err := p.ListZonesMatching(context.TODO(), "example1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// example1.com, example10.com, example11.com and example12.com
+	if len(result) != 4 {
+		t.Fatalf("len(result) != 4 => %d", len(result))
+	}
+	if count := m.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("GET requests != 1 => %d", count)
+	}
+
+	result, err = p.ListZonesMatching(context.TODO(), "example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 12 {
+		t.Fatalf("len(result) != 12 => %d", len(result))
+	}
+}
+
+func Test_GetAllRecords(t *testing.T) {
+	newMockAPI(t,
+		&mockZone{
+			bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+			Records:   []bunnyRecord{{ID: 10, Type: bunnyTypeTXT, Name: "test", Value: "test", TTL: 120}},
+		},
+		&mockZone{
+			bunnyZone: bunnyZone{ID: 2, Domain: "broken.com"},
+			fail:      true,
+		},
+		&mockZone{
+			bunnyZone: bunnyZone{ID: 3, Domain: "example.org"},
+			Records: []bunnyRecord{
+				{ID: 30, Type: bunnyTypeA, Name: "", Value: "127.0.0.1", TTL: 120},
+				{ID: 31, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+			},
+		},
+	)
+
+	p := &Provider{AccessKey: "key"}
+	result, err := p.GetAllRecords(context.TODO())
+	if err == nil || !strings.Contains(err.Error(), "broken.com") {
+		t.Fatalf("expected an error for broken.com, got %v", err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("len(result) != 2 => %d", len(result))
+	}
+	if len(result["example.com"]) != 1 || len(result["example.org"]) != 2 {
+		t.Fatalf("unexpected records => %+v", result)
+	}
+
+	// The errors of the zones so far are kept once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.EventLogger = func(e LogEvent) {
+		if e.Err != nil && e.Zone == "broken.com" {
+			cancel()
+		}
+	}
+	_, err = p.GetAllRecords(ctx)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "broken.com") {
+		t.Fatalf("expected context.Canceled and an error for broken.com, got %v", err)
+	}
+}
+
+func Test_ZoneSettings(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	err := p.SetZoneSettings(context.TODO(), "example.com", ZoneSettings{
+		LoggingEnabled:       true,
+		LogIPAnonymization:   true,
+		LogAnonymizationType: LogAnonymizationDrop,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := p.GetZoneSettings(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.LoggingEnabled || !settings.LogIPAnonymization || settings.LogAnonymizationType != LogAnonymizationDrop {
+		t.Fatalf("unexpected settings => %+v", settings)
+	}
+
+	if err := p.SetZoneSettings(context.TODO(), "example.com", ZoneSettings{LogAnonymizationType: 7}); err == nil {
+		t.Fatal("expected an error for an invalid anonymization type")
+	}
+
+	// A name within the zone resolves to the zone
+	settings, err = p.GetZoneSettings(context.TODO(), "www.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.LoggingEnabled {
+		t.Fatalf("unexpected settings => %+v", settings)
+	}
+
+	// The settings are only changed in delegated zones with CheckDelegation
+	p.CheckDelegation = true
+	err = p.SetZoneSettings(context.TODO(), "www.example.com", ZoneSettings{LogAnonymizationType: LogAnonymizationOneDigit})
+	if !errors.Is(err, ErrZoneNotDelegated) {
+		t.Fatalf("expected ErrZoneNotDelegated, got %v", err)
+	}
+	if !zone.LoggingEnabled {
+		t.Fatalf("settings changed => %+v", zone)
+	}
+}
+
+func Test_PageSize(t *testing.T) {
+	var zones []*mockZone
+	for i := 1; i <= 12; i++ {
+		zones = append(zones, &mockZone{bunnyZone: bunnyZone{ID: i, Domain: fmt.Sprintf("example%d.com", i)}})
+	}
+	m := newMockAPI(t, zones...)
+
+	p := &Provider{AccessKey: "key"}
+	if _, err := p.ListZones(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if count := m.requestCount(http.MethodGet); count != 1 {
+		t.Fatalf("GET requests with the default page size != 1 => %d", count)
+	}
+
+	p.PageSize = 5
+	result, err := p.ListZones(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 12 {
+		t.Fatalf("len(result) != 12 => %d", len(result))
+	}
+	if count := m.requestCount(http.MethodGet); count != 4 {
+		t.Fatalf("GET requests != 4 => %d", count)
+	}
+
+	for _, size := range []int{4, 1001, -1} {
+		p.PageSize = size
+		if _, err := p.ListZones(context.TODO()); err == nil {
+			t.Fatalf("expected an error for page size %d", size)
+		}
+	}
+}
+
+func Test_RecordsInZone(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	m := newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	resolved, err := p.GetZone(context.TODO(), "_acme-challenge.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ID != 1 || resolved.Name != "example.com." {
+		t.Fatalf("unexpected zone => %+v", resolved)
+	}
+	searches := len(m.requests)
+
+	records, err := p.AppendRecordsInZone(context.TODO(), resolved, []libdns.Record{
+		{Type: "TXT", Name: "test", Value: "test", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records[0].Value = "updated"
+	if _, err := p.SetRecordsInZone(context.TODO(), resolved, records); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := p.GetRecordsInZone(context.TODO(), resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Value != "updated" {
+		t.Fatalf("unexpected records => %+v", result)
+	}
+
+	deleted, err := p.DeleteRecordsInZone(context.TODO(), resolved, []libdns.Record{{Type: "TXT", Name: "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || len(zone.Records) != 0 {
+		t.Fatalf("unexpected deleted records => %+v", deleted)
+	}
+
+	for _, request := range m.requests[searches:] {
+		if request == "GET /dnszone" {
+			t.Fatalf("the zone was looked up => %v", m.requests[searches:])
+		}
+	}
+}
+
+func Test_nameBase(t *testing.T) {
+	zone := bunnyZone{Domain: "example.com", nameBase: "sub"}
+
+	testCases := []struct {
+		name, bunnyName string
+	}{
+		{"", "sub"},
+		{"@", "sub"},
+		{"a", "a.sub"},
+		{"sub", "sub.sub"},
+		{"a.sub", "a.sub.sub"},
+		{"A.Sub", "A.Sub.sub"},
+		{"sub.example.com.", "sub"},
+		{"a.sub.example.com", "a.sub"},
+		{"a.SUB.Example.com", "a.sub"},
+	}
+	for _, c := range testCases {
+		if bunnyName := zone.toBunnyName(c.name); bunnyName != c.bunnyName {
+			t.Fatalf("toBunnyName(%q) != %q => %q", c.name, c.bunnyName, bunnyName)
+		}
+	}
+
+	testCases = []struct {
+		name, bunnyName string
+	}{
+		{"", "sub"},
+		{"", "SUB"},
+		{"a", "a.sub"},
+		{"sub", "sub.sub"},
+		{"a.sub", "a.sub.sub"},
+		{"Mixed", "Mixed.Sub"},
+		{"asub", "asub"},
+		{"sub.other", "sub.other"},
+	}
+	for _, c := range testCases {
+		if name := zone.fromBunnyName(c.bunnyName); name != c.name {
+			t.Fatalf("fromBunnyName(%q) != %q => %q", c.bunnyName, c.name, name)
+		}
+	}
+}
+
+func Test_apexNameSymmetry(t *testing.T) {
+	for _, nameBase := range []string{"", "sub"} {
+		zone := bunnyZone{Domain: "example.com", nameBase: nameBase}
+
+		for _, name := range []string{"", "@", zone.domain(), zone.domain() + ".", strings.ToUpper(zone.domain())} {
+			bunnyName := zone.toBunnyName(name)
+			if bunnyName != nameBase {
+				t.Fatalf("toBunnyName(%q) with nameBase %q != %q => %q", name, nameBase, nameBase, bunnyName)
+			}
+			if result := zone.fromBunnyName(bunnyName); result != "" {
+				t.Fatalf(`fromBunnyName(%q) with nameBase %q != "" => %q`, bunnyName, nameBase, result)
+			}
+		}
+	}
+
+	// the API may also use "@" for the apex of the zone
+	if result := (bunnyZone{Domain: "example.com"}).fromBunnyName("@"); result != "" {
+		t.Fatalf(`fromBunnyName("@") != "" => %q`, result)
+	}
+}
+
+func Test_ApexRecordsRoundTrip(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	for domain, bunnyName := range map[string]string{"example.com": "", "sub.example.com": "sub"} {
+		appended, err := p.AppendRecords(context.TODO(), domain, []libdns.Record{{Type: "TXT", Name: "@", Value: domain, TTL: ttl}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if appended[0].Name != "" {
+			t.Fatalf(`appended[0].Name != "" => %q`, appended[0].Name)
+		}
+		if stored := zone.Records[0].Name; stored != bunnyName {
+			t.Fatalf("stored name != %q => %q", bunnyName, stored)
+		}
+
+		records, err := p.GetRecords(context.TODO(), domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, record := range records {
+			found = found || (record.Name == "" && record.Value == domain)
+		}
+		if !found {
+			t.Fatalf("apex record of %s not found => %+v", domain, records)
+		}
+
+		// the record is deleted by its name as returned
+		if _, err := p.DeleteRecords(context.TODO(), domain, []libdns.Record{{Type: "TXT", Name: "", Value: domain}}); err != nil {
+			t.Fatal(err)
+		}
+		if len(zone.Records) != 0 {
+			t.Fatalf("len(zone.Records) != 0 => %d", len(zone.Records))
+		}
+	}
+}
+
+func Test_RecordsInSubdomain(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "sub", Value: "127.0.0.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeTXT, Name: "a.sub", Value: "a", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "sub.sub", Value: "sub", TTL: 120},
+			{ID: 13, Type: bunnyTypeTXT, Name: "asub", Value: "asub", TTL: 120},
+			{ID: 14, Type: bunnyTypeTXT, Name: "a", Value: "a", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key"}
+	records, err := p.GetRecords(context.TODO(), "sub.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, record := range records {
+		names = append(names, record.Name)
+	}
+	if strings.Join(names, ",") != ",a,sub" {
+		t.Fatalf(`names != ",a,sub" => %q`, strings.Join(names, ","))
+	}
+
+	appended, err := p.AppendRecords(context.TODO(), "sub.example.com.", []libdns.Record{
+		{Type: "TXT", Name: "b", Value: "b", TTL: ttl},
+		{Type: "TXT", Name: "", Value: "apex", TTL: ttl},
+		{Type: "TXT", Name: "c.sub.example.com.", Value: "c", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct{ name, bunnyName string }{{"b", "b.sub"}, {"", "sub"}, {"c", "c.sub"}}
+	for k, e := range expected {
+		if appended[k].Name != e.name {
+			t.Fatalf("appended[%d].Name != %q => %q", k, e.name, appended[k].Name)
+		}
+		if stored := zone.Records[5+k].Name; stored != e.bunnyName {
+			t.Fatalf("stored name != %q => %q", e.bunnyName, stored)
+		}
+	}
+
+	// Updating "a" keeps it at "a.sub"
+	if _, err := p.SetRecords(context.TODO(), "sub.example.com", []libdns.Record{{Type: "TXT", Name: "a", Value: "updated", TTL: ttl}}); err != nil {
+		t.Fatal(err)
+	}
+	if zone.Records[1].Name != "a.sub" || zone.Records[1].Value != "updated" {
+		t.Fatalf("unexpected record => %+v", zone.Records[1])
+	}
+
+	// Only "a" in the subdomain is deleted, not "a" in the zone
+	deleted, err := p.DeleteRecords(context.TODO(), "sub.example.com", []libdns.Record{{Type: "TXT", Name: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != "11" || deleted[0].Name != "a" {
+		t.Fatalf("unexpected deleted records => %+v", deleted)
+	}
+}
+
+func Test_ApexName(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "", Value: "127.0.0.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone)
+
+	testCases := []struct {
+		apexName ApexName
+		expected string
+	}{
+		{ApexNameEmpty, ""},
+		{ApexNameAt, "@"},
+		{ApexNameFQDN, "example.com."},
+	}
+
+	for _, c := range testCases {
+		p := &Provider{AccessKey: "key", ApexName: c.apexName}
+		records, err := p.GetRecords(context.TODO(), "example.com.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if records[0].Name != c.expected || records[1].Name != "www" {
+			t.Fatalf("unexpected names for ApexName %d => %q, %q", c.apexName, records[0].Name, records[1].Name)
+		}
+
+		// Any representation is accepted as input
+		appended, err := p.AppendRecords(context.TODO(), "example.com.", []libdns.Record{{Type: "TXT", Name: c.expected, Value: "test", TTL: ttl}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if appended[0].Name != c.expected {
+			t.Fatalf("appended[0].Name != %q => %q", c.expected, appended[0].Name)
+		}
+		if stored := zone.Records[len(zone.Records)-1].Name; stored != "" {
+			t.Fatalf(`stored name != "" => %q`, stored)
+		}
+	}
+}
+
+func Test_ApexNameAllPaths(t *testing.T) {
+	zone := &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "", Value: "127.0.0.1", TTL: 120},
+		},
+	}
+	src := &mockZone{
+		bunnyZone: bunnyZone{ID: 2, Domain: "example.org"},
+		Records: []bunnyRecord{
+			{ID: 20, Type: bunnyTypeTXT, Name: "", Value: "copied", TTL: 120},
+		},
+	}
+	newMockAPI(t, zone, src)
+
+	p := &Provider{AccessKey: "key", ApexName: ApexNameAt}
+	ctx := context.TODO()
+
+	all, err := p.GetAllRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all["example.com"][0].Name != "@" {
+		t.Fatalf("GetAllRecords: unexpected name => %q", all["example.com"][0].Name)
+	}
+
+	existing, _, err := p.CreateRecordIfAbsent(ctx, "example.com", libdns.Record{Type: "A", Name: "@", Value: "127.0.0.1", TTL: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, _, err := p.CreateRecordIfAbsent(ctx, "example.com", libdns.Record{Type: "TXT", Name: "", Value: "new", TTL: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if existing.Name != "@" || created.Name != "@" {
+		t.Fatalf("CreateRecordIfAbsent: unexpected names => %q, %q", existing.Name, created.Name)
+	}
+
+	copied, err := p.CopyZoneRecords(ctx, "example.org", "example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied) != 1 || copied[0].Name != "@" {
+		t.Fatalf("CopyZoneRecords: unexpected records => %+v", copied)
+	}
+
+	pullZone, err := p.CreatePullZoneRecord(ctx, "example.com", "", 42, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := p.CreateScriptRecord(ctx, "example.com", "", 42, nil, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pullZone.Name != "@" || script.Name != "@" {
+		t.Fatalf("unexpected names => %q, %q", pullZone.Name, script.Name)
+	}
+
+	summary, err := p.MergeRecords(ctx, "example.com", []libdns.Record{
+		{Type: "A", Name: "", Value: "127.0.0.1", TTL: ttl},
+		{Type: "AAAA", Name: "", Value: "::1", TTL: ttl},
+	}, MergeKeepExisting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Unchanged[0].Name != "@" || summary.Created[0].Name != "@" {
+		t.Fatalf("MergeRecords: unexpected summary => %+v", summary)
+	}
+}
+
+func Test_ZoneRecordCount(t *testing.T) {
+	m := newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "", Value: "127.0.0.1", TTL: 120},
+			{ID: 11, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+			{ID: 12, Type: bunnyTypeTXT, Name: "www", Value: "test", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	count, err := p.ZoneRecordCount(context.TODO(), "www.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("count != 3 => %d", count)
+	}
+
+	// Only the zone lookup is requested
+	for _, request := range m.requests {
+		if strings.HasPrefix(request, "GET /dnszone/") {
+			t.Fatalf("unexpected request => %s", request)
+		}
+	}
+}
+
+func Test_ZoneByID(t *testing.T) {
+	m := newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 1, Domain: "example.com"},
+		Records: []bunnyRecord{
+			{ID: 10, Type: bunnyTypeA, Name: "www", Value: "127.0.0.1", TTL: 120},
+		},
+	})
+
+	p := &Provider{AccessKey: "key"}
+	zone, err := p.GetZoneByID(context.TODO(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Name != "example.com." || zone.ID != 1 {
+		t.Fatalf("unexpected zone => %+v", zone)
+	}
+
+	// The name may be omitted if only relative names are used
+	records, err := p.AppendRecordsInZone(context.TODO(), Zone{ID: 1}, []libdns.Record{{Type: "TXT", Name: "test", Value: "test", TTL: ttl}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "test" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+
+	for _, request := range m.requests {
+		if request == "GET /dnszone" {
+			t.Fatalf("unexpected zone lookup")
+		}
+	}
+
+	if _, err := p.GetZoneByID(context.TODO(), 2); err == nil {
+		t.Fatalf("expected an error for an unknown zone")
+	}
+}
+
+func Test_WildcardRecords(t *testing.T) {
+	zone := &mockZone{bunnyZone: bunnyZone{ID: 1, Domain: "example.com"}}
+	newMockAPI(t, zone)
+
+	p := &Provider{AccessKey: "key", ApexName: ApexNameAt}
+	appended, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{
+		{Type: "A", Name: "*", Value: "127.0.0.1", TTL: ttl},
+		{Type: "TXT", Name: "*.sub", Value: "sub", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appended[0].Name != "*" || appended[1].Name != "*.sub" {
+		t.Fatalf("unexpected names => %q, %q", appended[0].Name, appended[1].Name)
+	}
+
+	// the wildcard of the subdomain is "*" relative to it
+	subAppended, err := p.AppendRecords(context.TODO(), "sub.example.com", []libdns.Record{
+		{Type: "A", Name: "*", Value: "127.0.0.2", TTL: ttl},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subAppended[0].Name != "*" {
+		t.Fatalf(`subAppended[0].Name != "*" => %q`, subAppended[0].Name)
+	}
+
+	for i, name := range []string{"*", "*.sub", "*.sub"} {
+		if zone.Records[i].Name != name {
+			t.Fatalf("zone.Records[%d].Name != %q => %q", i, name, zone.Records[i].Name)
+		}
+	}
+
+	records, err := p.GetRecords(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].Name != "*" || records[1].Name != "*.sub" || records[2].Name != "*.sub" {
+		t.Fatalf("unexpected records => %+v", records)
+	}
+
+	subRecords, err := p.GetRecords(context.TODO(), "sub.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subRecords) != 2 || subRecords[0].Name != "*" || subRecords[1].Name != "*" {
+		t.Fatalf("unexpected records => %+v", subRecords)
+	}
+
+	// wildcards are deleted by name, without affecting other names
+	if _, err := p.DeleteRecords(context.TODO(), "sub.example.com", []libdns.Record{{Type: "A", Name: "*"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.DeleteRecords(context.TODO(), "example.com", []libdns.Record{{Type: "A", Name: "*.example.com."}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(zone.Records) != 1 || zone.Records[0].Name != "*.sub" || zone.Records[0].Type != bunnyTypeTXT {
+		t.Fatalf("unexpected remaining records => %+v", zone.Records)
+	}
+}
+
+func Test_OnZoneNotFoundFetchesZone(t *testing.T) {
+	newMockAPI(t, &mockZone{
+		bunnyZone: bunnyZone{ID: 7, Domain: "example.com", NameserversDetected: true},
+		Records:   []bunnyRecord{{ID: 70, Type: bunnyTypeA, Name: "www", Value: "1.2.3.4", TTL: 120}},
+		unlisted:  true,
+	})
+
+	p := &Provider{
+		AccessKey:       "key",
+		CheckDelegation: true,
+		OnZoneNotFound: func(ctx context.Context, domain string) (Zone, error) {
+			return Zone{Zone: libdns.Zone{Name: "example.com."}, ID: 7}, nil
+		},
+	}
+
+	// the zone supplied by the hook has the data of the zone lookup
+	count, err := p.ZoneRecordCount(context.TODO(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("count != 1 => %d", count)
+	}
+	if _, err := p.AppendRecords(context.TODO(), "example.com", []libdns.Record{{Type: "TXT", Name: "_acme-challenge", Value: "token", TTL: ttl}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_OnZoneNotFound(t *testing.T) {
+	m := newMockAPI(t)
+
+	var domains []string
+	p := &Provider{
+		AccessKey: "key",
+		OnZoneNotFound: func(ctx context.Context, domain string) (Zone, error) {
+			domains = append(domains, domain)
+
+			// provision the zone
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.zones = append(m.zones, &mockZone{bunnyZone: bunnyZone{ID: 7, Domain: "example.com"}})
+			return Zone{Zone: libdns.Zone{Name: "example.com."}, ID: 7}, nil
+		},
+	}
+
+	records, err := p.AppendRecords(context.TODO(), "_acme-challenge.example.com", []libdns.Record{{Type: "TXT", Name: "", Value: "token", TTL: ttl}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 1 || domains[0] != "_acme-challenge.example.com" {
+		t.Fatalf("unexpected domains => %v", domains)
+	}
+	if len(records) != 1 || m.zones[0].Records[0].Name != "_acme-challenge" {
+		t.Fatalf("unexpected records => %+v", m.zones[0].Records)
+	}
+
+	// the hook may decline to supply a zone
+	p.OnZoneNotFound = func(ctx context.Context, domain string) (Zone, error) {
+		return Zone{}, nil
+	}
+	if _, err := p.GetRecords(context.TODO(), "example.org"); !errors.Is(err, ErrZoneNotFound) {
+		t.Fatalf("expected ErrZoneNotFound, got %v", err)
+	}
+
+	// the supplied zone must contain the domain
+	p.OnZoneNotFound = func(ctx context.Context, domain string) (Zone, error) {
+		return Zone{Zone: libdns.Zone{Name: "example.com."}, ID: 7}, nil
+	}
+	if _, err := p.GetRecords(context.TODO(), "example.org"); err == nil {
+		t.Fatal("expected an error for a zone not containing the domain")
+	}
+}